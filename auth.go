@@ -0,0 +1,118 @@
+package gonsx
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+)
+
+// Authenticator adds credentials to every request built by NSXClient.NewRequest
+type Authenticator interface {
+	Authenticate(req *http.Request, nsxConfig *NSXClient) error
+}
+
+// Reauthenticator is implemented by authenticators whose credentials can expire. When NSX
+// rejects a request with 401 or 403, Do calls Reauthenticate once and resends the request.
+type Reauthenticator interface {
+	Reauthenticate(req *http.Request, nsxConfig *NSXClient) error
+}
+
+// authCloser is implemented by authenticators that hold state on NSX Manager
+type authCloser interface {
+	Close(ctx context.Context, nsxConfig *NSXClient) error
+}
+
+// BasicAuth sends the username and password with every request. This is what NSXClient
+// does with its Username and Password fields when no Auth is set.
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+func (a *BasicAuth) Authenticate(req *http.Request, nsxConfig *NSXClient) error {
+	req.SetBasicAuth(a.Username, a.Password)
+	return nil
+}
+
+// BearerTokenAuth sends a token, e.g. issued by vIDM, as Authorization: Bearer header.
+// When TokenFunc is set it is called for every request, so expiring tokens can be renewed.
+type BearerTokenAuth struct {
+	Token     string
+	TokenFunc func(ctx context.Context) (string, error)
+}
+
+func (a *BearerTokenAuth) Authenticate(req *http.Request, nsxConfig *NSXClient) error {
+	token := a.Token
+	if a.TokenFunc != nil {
+		var err error
+		token, err = a.TokenFunc(req.Context())
+		if err != nil {
+			return fmt.Errorf("error getting bearer token: %w", err)
+		}
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// CertificateAuth authenticates as an NSX principal identity with a client certificate.
+// The certificate is presented during the TLS handshake, so the NSXClient must use the
+// http client returned by HTTPClient, no header is added to the requests.
+type CertificateAuth struct {
+	Certificate tls.Certificate
+}
+
+// NewCertificateAuth loads the principal identity certificate and key from PEM files
+func NewCertificateAuth(certFile, keyFile string) (*CertificateAuth, error) {
+	certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading client certificate: %w", err)
+	}
+	return &CertificateAuth{Certificate: certificate}, nil
+}
+
+// HTTPClient returns a copy of base (or of http.DefaultClient when nil) whose transport
+// presents the client certificate. base must use an *http.Transport or no transport at all.
+func (a *CertificateAuth) HTTPClient(base *http.Client) (*http.Client, error) {
+	if base == nil {
+		base = http.DefaultClient
+	}
+	client := *base
+
+	var transport *http.Transport
+	switch baseTransport := base.Transport.(type) {
+	case nil:
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		transport = baseTransport.Clone()
+	default:
+		return nil, fmt.Errorf("unsupported transport %T for client certificate authentication", base.Transport)
+	}
+
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	transport.TLSClientConfig.Certificates = []tls.Certificate{a.Certificate}
+	client.Transport = transport
+
+	return &client, nil
+}
+
+func (a *CertificateAuth) Authenticate(req *http.Request, nsxConfig *NSXClient) error {
+	req.Header.Del("Authorization")
+	return nil
+}
+
+// authenticator returns the configured Authenticator, falling back to basic auth
+func (nsxConfig *NSXClient) authenticator() Authenticator {
+	if nsxConfig.Auth != nil {
+		return nsxConfig.Auth
+	}
+	return &BasicAuth{Username: nsxConfig.Username, Password: nsxConfig.Password}
+}
+
+// isAuthExpired reports whether NSX rejected the request because its credentials expired
+func isAuthExpired(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden
+}
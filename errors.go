@@ -0,0 +1,132 @@
+package gonsx
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// sentinels to use with errors.Is on errors returned by the library
+var (
+	ErrNotFound         = errors.New("not found")
+	ErrConflict         = errors.New("conflict")
+	ErrRevisionMismatch = errors.New("revision mismatch")
+	ErrUnauthorized     = errors.New("unauthorized")
+	ErrThrottled        = errors.New("throttled")
+)
+
+// NSX error codes that indicate the object was modified since it was read
+var revisionMismatchErrorCodes = map[int]bool{
+	604:    true,
+	500125: true,
+}
+
+// APIError is a non 2xx response from NSX, with the error body NSX returns decoded
+type APIError struct {
+	// HTTP status code of the response
+	StatusCode int `json:"-"`
+	// Method and URL of the request that failed
+	Method string `json:"-"`
+	URL    string `json:"-"`
+	// Raw response body, kept when it is not an NSX error body
+	Body string `json:"-"`
+
+	ErrorCode     int        `json:"error_code,omitempty"`
+	ErrorMessage  string     `json:"error_message,omitempty"`
+	ModuleName    string     `json:"module_name,omitempty"`
+	Details       string     `json:"details,omitempty"`
+	RelatedErrors []APIError `json:"related_errors,omitempty"`
+}
+
+// newAPIError builds an APIError from a failed response, consuming the body
+func newAPIError(resp *http.Response) *APIError {
+	apiError := &APIError{StatusCode: resp.StatusCode}
+
+	if resp.Request != nil {
+		apiError.Method = resp.Request.Method
+		apiError.URL = resp.Request.URL.String()
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	err := json.Unmarshal(body, apiError)
+	if err != nil || apiError.ErrorMessage == "" {
+		apiError.Body = strings.TrimSpace(string(body))
+	}
+
+	return apiError
+}
+
+func (e *APIError) Error() string {
+	message := e.ErrorMessage
+	if message == "" {
+		message = e.Body
+	}
+	if message == "" && e.StatusCode == http.StatusForbidden {
+		message = "Unauthorized, please verify credentials, and vIDM status"
+	}
+	if message == "" {
+		message = http.StatusText(e.StatusCode)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "HTTP %d", e.StatusCode)
+	if e.Method != "" {
+		fmt.Fprintf(&sb, " on %s %s", e.Method, e.URL)
+	}
+	if e.ErrorCode != 0 {
+		fmt.Fprintf(&sb, ": %s error %d", e.ModuleName, e.ErrorCode)
+	}
+	fmt.Fprintf(&sb, ": %s", message)
+
+	for _, related := range e.RelatedErrors {
+		fmt.Fprintf(&sb, "; %s error %d: %s", related.ModuleName, related.ErrorCode, related.ErrorMessage)
+	}
+
+	return sb.String()
+}
+
+// Is lets errors.Is match an APIError against the sentinel errors
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict || e.isRevisionMismatch()
+	case ErrRevisionMismatch:
+		return e.isRevisionMismatch()
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrThrottled:
+		return e.StatusCode == http.StatusTooManyRequests || e.StatusCode == http.StatusServiceUnavailable
+	}
+	return false
+}
+
+func (e *APIError) isRevisionMismatch() bool {
+	return e.StatusCode == http.StatusPreconditionFailed || revisionMismatchErrorCodes[e.ErrorCode]
+}
+
+// RevisionConflictError is returned when NSX rejects a write because the _revision sent
+// with it is missing or stale, i.e. somebody else modified the object in the meantime.
+type RevisionConflictError struct {
+	// Policy path of the object that was written
+	Path string
+	// Revision that was sent with the write
+	Revision *int32
+	// Error returned by NSX
+	Err *APIError
+}
+
+func (e *RevisionConflictError) Error() string {
+	if e.Revision == nil {
+		return fmt.Sprintf("revision conflict on %s: %v", e.Path, e.Err)
+	}
+	return fmt.Sprintf("revision conflict on %s at revision %d: %v", e.Path, *e.Revision, e.Err)
+}
+
+func (e *RevisionConflictError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,286 @@
+package gonsx
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// GroupMembers are the effective members of a group, as reported by NSX
+type GroupMembers struct {
+	// Display names of the member VMs
+	VirtualMachines []string
+	// Member IP addresses, CIDRs and ranges
+	IPAddresses []string
+}
+
+// EffectiveEndpoints are the resolved source or destination of a rule
+type EffectiveEndpoints struct {
+	// The rule matches any endpoint
+	Any bool
+	// The rule matches everything except these endpoints, see Rule.SourcesExcluded
+	Excluded bool
+	// Paths of the groups the endpoints were resolved from
+	Groups []string
+	// Display names of the VMs
+	VirtualMachines []string
+	// IP addresses, CIDRs and ranges, both group members and addresses set directly on the rule
+	IPAddresses []string
+}
+
+// EffectiveServices are the resolved services of a rule, nested services are flattened
+type EffectiveServices struct {
+	// The rule matches any service
+	Any        bool
+	L4PortSets []L4PortSetServiceEntry
+	Icmp       []IcmpServiceEntry
+	// All other service entry types, e.g. ALG, IP protocol and ether type entries
+	Other []DynamicServiceEntry
+}
+
+// EffectiveRule is a rule with its group and service references resolved
+type EffectiveRule struct {
+	Rule         Rule
+	Sources      EffectiveEndpoints
+	Destinations EffectiveEndpoints
+	Services     EffectiveServices
+}
+
+// RuleExpander resolves the group and service references of rules. Group members and services
+// are cached, so expanding many rules sharing groups only looks each of them up once.
+type RuleExpander struct {
+	nsxConfig *NSXClient
+
+	mu       sync.Mutex
+	groups   map[string]*GroupMembers
+	services map[string]*Service
+}
+
+// NewRuleExpander creates an expander with an empty cache
+func NewRuleExpander(nsxConfig *NSXClient) *RuleExpander {
+	return &RuleExpander{
+		nsxConfig: nsxConfig,
+		groups:    map[string]*GroupMembers{},
+		services:  map[string]*Service{},
+	}
+}
+
+// ExpandSecurityPolicy expands all rules of the policy, in the order they are listed
+func (e *RuleExpander) ExpandSecurityPolicy(ctx context.Context, policy SecurityPolicy) ([]EffectiveRule, error) {
+	effectiveRules := make([]EffectiveRule, 0, len(policy.Rules))
+
+	for _, rule := range policy.Rules {
+		effectiveRule, err := e.ExpandRule(ctx, rule)
+		if err != nil {
+			return nil, err
+		}
+		effectiveRules = append(effectiveRules, *effectiveRule)
+	}
+
+	return effectiveRules, nil
+}
+
+// ExpandRule resolves the source groups, destination groups and services of a rule
+func (e *RuleExpander) ExpandRule(ctx context.Context, rule Rule) (*EffectiveRule, error) {
+	sources, err := e.expandEndpoints(ctx, rule.SourceGroups, rule.SourcesExcluded)
+	if err != nil {
+		return nil, fmt.Errorf("error expanding sources of rule %s: %w", ruleName(rule), err)
+	}
+
+	destinations, err := e.expandEndpoints(ctx, rule.DestinationGroups, rule.DestinationsExcluded)
+	if err != nil {
+		return nil, fmt.Errorf("error expanding destinations of rule %s: %w", ruleName(rule), err)
+	}
+
+	services, err := expandServices(rule, func(path string) (*Service, error) {
+		return e.service(ctx, path)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error expanding services of rule %s: %w", ruleName(rule), err)
+	}
+
+	return &EffectiveRule{
+		Rule:         rule,
+		Sources:      *sources,
+		Destinations: *destinations,
+		Services:     *services,
+	}, nil
+}
+
+func (e *RuleExpander) expandEndpoints(ctx context.Context, entries []string, excluded *bool) (*EffectiveEndpoints, error) {
+	return expandEndpoints(entries, excluded, func(path string) (*GroupMembers, error) {
+		return e.groupMembers(ctx, path)
+	})
+}
+
+// groupMembers returns the cached members of the group at path, looking them up on a miss
+func (e *RuleExpander) groupMembers(ctx context.Context, path string) (*GroupMembers, error) {
+	e.mu.Lock()
+	members, ok := e.groups[path]
+	e.mu.Unlock()
+	if ok {
+		return members, nil
+	}
+
+	group := &Group{BaseNsxPolicyApiResource: BaseNsxPolicyApiResource{Path: &path}}
+
+	vmMembers, err := group.GetVmMembersWithContext(ctx, e.nsxConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error getting vm members of %s: %w", path, err)
+	}
+
+	ipMembers, err := group.GetIPAddressMembersWithContext(ctx, e.nsxConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error getting ip members of %s: %w", path, err)
+	}
+
+	members = &GroupMembers{VirtualMachines: vmMembers, IPAddresses: ipMembers}
+
+	e.mu.Lock()
+	e.groups[path] = members
+	e.mu.Unlock()
+
+	return members, nil
+}
+
+// service returns the cached service at path, looking it up on a miss
+func (e *RuleExpander) service(ctx context.Context, path string) (*Service, error) {
+	e.mu.Lock()
+	service, ok := e.services[path]
+	e.mu.Unlock()
+	if ok {
+		return service, nil
+	}
+
+	service, err := GetServiceByPath(ctx, e.nsxConfig, path)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	e.services[path] = service
+	e.mu.Unlock()
+
+	return service, nil
+}
+
+// expandEndpoints resolves the group paths in entries with lookup, other entries are IP addresses
+func expandEndpoints(entries []string, excluded *bool, lookup func(path string) (*GroupMembers, error)) (*EffectiveEndpoints, error) {
+	endpoints := &EffectiveEndpoints{Excluded: excluded != nil && *excluded}
+
+	if len(entries) == 0 {
+		endpoints.Any = true
+		return endpoints, nil
+	}
+
+	for _, entry := range entries {
+		switch {
+		case strings.EqualFold(entry, "ANY"):
+			endpoints.Any = true
+		case strings.HasPrefix(entry, "/"):
+			members, err := lookup(entry)
+			if err != nil {
+				return nil, err
+			}
+			endpoints.Groups = append(endpoints.Groups, entry)
+			endpoints.VirtualMachines = appendUnique(endpoints.VirtualMachines, members.VirtualMachines...)
+			endpoints.IPAddresses = appendUnique(endpoints.IPAddresses, members.IPAddresses...)
+		default:
+			endpoints.IPAddresses = appendUnique(endpoints.IPAddresses, entry)
+		}
+	}
+
+	return endpoints, nil
+}
+
+// expandServices resolves the services of a rule with lookup, following nested services
+func expandServices(rule Rule, lookup func(path string) (*Service, error)) (*EffectiveServices, error) {
+	services := &EffectiveServices{}
+
+	if len(rule.Services) == 0 && len(rule.ServiceEntries) == 0 {
+		services.Any = true
+		return services, nil
+	}
+
+	visited := map[string]bool{}
+
+	for _, path := range rule.Services {
+		if strings.EqualFold(path, "ANY") {
+			services.Any = true
+			continue
+		}
+		err := services.addService(path, lookup, visited)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := services.addEntries(rule.ServiceEntries, lookup, visited)
+	if err != nil {
+		return nil, err
+	}
+
+	return services, nil
+}
+
+func (s *EffectiveServices) addService(path string, lookup func(path string) (*Service, error), visited map[string]bool) error {
+	// a service nested in itself, directly or not, adds nothing new
+	if visited[path] {
+		return nil
+	}
+	visited[path] = true
+
+	service, err := lookup(path)
+	if err != nil {
+		return fmt.Errorf("error getting service %s: %w", path, err)
+	}
+
+	return s.addEntries(service.ServiceEntries, lookup, visited)
+}
+
+func (s *EffectiveServices) addEntries(entries []DynamicServiceEntryWrapper, lookup func(path string) (*Service, error), visited map[string]bool) error {
+	for _, wrapper := range entries {
+		switch entry := wrapper.ServiceEntry.(type) {
+		case *L4PortSetServiceEntry:
+			s.L4PortSets = append(s.L4PortSets, *entry)
+		case *IcmpServiceEntry:
+			s.Icmp = append(s.Icmp, *entry)
+		case *NestedServiceEntry:
+			err := s.addService(entry.NestedServicePath, lookup, visited)
+			if err != nil {
+				return err
+			}
+		default:
+			s.Other = append(s.Other, entry)
+		}
+	}
+	return nil
+}
+
+// appendUnique appends the values not in list yet
+func appendUnique(list []string, values ...string) []string {
+	seen := make(map[string]bool, len(list)+len(values))
+	for _, existing := range list {
+		seen[existing] = true
+	}
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+func ruleName(rule Rule) string {
+	switch {
+	case rule.RuleId != nil:
+		return fmt.Sprint(*rule.RuleId)
+	case rule.Path != nil:
+		return *rule.Path
+	case rule.Id != nil:
+		return *rule.Id
+	}
+	return "<unnamed>"
+}
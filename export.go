@@ -0,0 +1,576 @@
+package gonsx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl"
+	hclprinter "github.com/hashicorp/hcl/hcl/printer"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Exported objects are written one file per object, below the export directory:
+//
+//	groups/<domain>/<group id>.yaml
+//	services/<service id>.yaml
+//	security-policies/<domain>/<policy id>.yaml
+//
+// Rules are part of their security policy file. Server managed fields (see ServerManagedFields)
+// are stripped, the policy path of an object is derived from its location when importing.
+const (
+	exportGroupsDir   = "groups"
+	exportServicesDir = "services"
+	exportPoliciesDir = "security-policies"
+)
+
+// Format encodes and decodes exported objects
+type Format interface {
+	// File extension without the dot, e.g. yaml
+	Extension() string
+	Marshal(object map[string]any) ([]byte, error)
+	Unmarshal(data []byte) (map[string]any, error)
+}
+
+var (
+	FormatJSON Format = jsonFormat{}
+	FormatYAML Format = yamlFormat{}
+	FormatTOML Format = tomlFormat{}
+	FormatHCL  Format = hclFormat{}
+)
+
+var formatsByExtension = map[string]Format{
+	"json": FormatJSON,
+	"yaml": FormatYAML,
+	"yml":  FormatYAML,
+	"toml": FormatTOML,
+	"hcl":  FormatHCL,
+}
+
+// FormatForExtension returns the format of a file extension like yaml or .json
+func FormatForExtension(extension string) (Format, error) {
+	format, ok := formatsByExtension[strings.ToLower(strings.TrimPrefix(extension, "."))]
+	if !ok {
+		return nil, fmt.Errorf("unsupported file extension %q", extension)
+	}
+	return format, nil
+}
+
+type jsonFormat struct{}
+
+func (jsonFormat) Extension() string { return "json" }
+
+func (jsonFormat) Marshal(object map[string]any) ([]byte, error) {
+	encoded, err := json.MarshalIndent(object, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(encoded, '\n'), nil
+}
+
+func (jsonFormat) Unmarshal(data []byte) (map[string]any, error) {
+	var object map[string]any
+	err := decodeJSONNumbers(data, &object)
+	return object, err
+}
+
+// decodeJSONNumbers decodes json keeping numbers as json.Number, so integers don't lose
+// precision by going through float64
+func decodeJSONNumbers(data []byte, out any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(out)
+}
+
+type yamlFormat struct{}
+
+func (yamlFormat) Extension() string { return "yaml" }
+
+func (yamlFormat) Marshal(object map[string]any) ([]byte, error) {
+	return yaml.Marshal(object)
+}
+
+func (yamlFormat) Unmarshal(data []byte) (map[string]any, error) {
+	var object map[string]any
+	err := yaml.Unmarshal(data, &object)
+	return object, err
+}
+
+type tomlFormat struct{}
+
+func (tomlFormat) Extension() string { return "toml" }
+
+func (tomlFormat) Marshal(object map[string]any) ([]byte, error) {
+	return toml.Marshal(object)
+}
+
+func (tomlFormat) Unmarshal(data []byte) (map[string]any, error) {
+	var object map[string]any
+	err := toml.Unmarshal(data, &object)
+	return object, err
+}
+
+// hclFormat reads and writes HCL 1. HCL has no way to tell a nested object from a list with a
+// single object, importHCL uses the Go types to undo that.
+type hclFormat struct{}
+
+func (hclFormat) Extension() string { return "hcl" }
+
+func (hclFormat) Marshal(object map[string]any) ([]byte, error) {
+	encoded, err := json.Marshal(object)
+	if err != nil {
+		return nil, err
+	}
+
+	// the HCL parser accepts json, the printer writes the result as HCL
+	tree, err := hcl.ParseBytes(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	err = hclprinter.Fprint(&buf, tree.Node)
+	if err != nil {
+		return nil, err
+	}
+	buf.WriteString("\n")
+
+	return buf.Bytes(), nil
+}
+
+func (hclFormat) Unmarshal(data []byte) (map[string]any, error) {
+	var object map[string]any
+	err := hcl.Unmarshal(data, &object)
+	return object, err
+}
+
+// ExportOptions controls what Export writes
+type ExportOptions struct {
+	// Defaults to FormatYAML
+	Format Format
+	// Export system owned objects as well, like the default services and groups
+	IncludeSystemOwned bool
+	// Remove files of the same format in the export directory that don't belong to an exported object
+	RemoveStale bool
+}
+
+// Export fetches all groups, services and security policies from NSX and writes them to dir
+func Export(ctx context.Context, nsxConfig NSXClient, dir string, opts ExportOptions) error {
+	objects, err := FetchObjectSet(ctx, nsxConfig)
+	if err != nil {
+		return err
+	}
+	return ExportObjectSet(objects, dir, opts)
+}
+
+// ExportObjectSet writes the objects to dir, one file per object
+func ExportObjectSet(objects *ObjectSet, dir string, opts ExportOptions) error {
+	format := opts.Format
+	if format == nil {
+		format = FormatYAML
+	}
+
+	written := map[string]bool{}
+
+	write := func(object reconcileObject, file string) error {
+		if object.resource.SystemOwned != nil && *object.resource.SystemOwned && !opts.IncludeSystemOwned {
+			return nil
+		}
+
+		normalized, err := normalizeObject(object.object)
+		if err != nil {
+			return fmt.Errorf("error encoding %s %s: %w", object.kind, object.path, err)
+		}
+		fields, ok := normalized.(map[string]any)
+		if !ok {
+			return fmt.Errorf("error encoding %s %s: not an object", object.kind, object.path)
+		}
+
+		encoded, err := format.Marshal(fields)
+		if err != nil {
+			return fmt.Errorf("error encoding %s %s: %w", object.kind, object.path, err)
+		}
+
+		file = filepath.Join(dir, file+"."+format.Extension())
+		if written[file] {
+			return fmt.Errorf("%s %s is exported twice to %s", object.kind, object.path, file)
+		}
+		written[file] = true
+
+		err = os.MkdirAll(filepath.Dir(file), 0o755)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(file, encoded, 0o644)
+	}
+
+	for _, object := range objects.objects() {
+		if object.resource.Id == nil {
+			return fmt.Errorf("%s %s has no id", object.kind, object.path)
+		}
+		id := url.PathEscape(*object.resource.Id)
+		domain := url.PathEscape(domainFromPath(object.path))
+
+		var file string
+		switch object.kind {
+		case "Group":
+			file = filepath.Join(exportGroupsDir, domain, id)
+		case "Service":
+			file = filepath.Join(exportServicesDir, id)
+		case "SecurityPolicy":
+			file = filepath.Join(exportPoliciesDir, domain, id)
+		}
+
+		err := write(object, file)
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.RemoveStale {
+		return removeStaleExports(dir, format, written)
+	}
+	return nil
+}
+
+func removeStaleExports(dir string, format Format, written map[string]bool) error {
+	for _, kindDir := range []string{exportGroupsDir, exportServicesDir, exportPoliciesDir} {
+		err := filepath.WalkDir(filepath.Join(dir, kindDir), func(file string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if entry.IsDir() || written[file] || filepath.Ext(file) != "."+format.Extension() {
+				return nil
+			}
+			return os.Remove(file)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Import reads the objects exported to dir, the format of each file follows from its extension.
+// The objects get their policy path from the file location, ready for ComputePlan or the CRUD functions.
+func Import(dir string) (*ObjectSet, error) {
+	objects := &ObjectSet{}
+
+	err := importDir(dir, exportGroupsDir, true, func(file, domain string, fields map[string]any, format Format) error {
+		var group Group
+		err := decodeImported(fields, format, &group)
+		if err != nil {
+			return err
+		}
+		if group.Id == nil {
+			return fmt.Errorf("group has no id")
+		}
+		group.Path = stringPointer(GroupPath(domain, *group.Id))
+		objects.Groups = append(objects.Groups, group)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = importDir(dir, exportServicesDir, false, func(file, domain string, fields map[string]any, format Format) error {
+		var service Service
+		err := decodeImported(fields, format, &service)
+		if err != nil {
+			return err
+		}
+		if service.Id == nil {
+			return fmt.Errorf("service has no id")
+		}
+		service.Path = stringPointer(ServicePath(*service.Id))
+		objects.Services = append(objects.Services, service)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = importDir(dir, exportPoliciesDir, true, func(file, domain string, fields map[string]any, format Format) error {
+		var policy SecurityPolicy
+		err := decodeImported(fields, format, &policy)
+		if err != nil {
+			return err
+		}
+		if policy.Id == nil {
+			return fmt.Errorf("security policy has no id")
+		}
+		policy.Path = stringPointer(SecurityPolicyPath(domain, *policy.Id))
+		for i, rule := range policy.Rules {
+			if rule.Id == nil {
+				return fmt.Errorf("rule %d has no id", i)
+			}
+			policy.Rules[i].Path = stringPointer(RulePath(domain, *policy.Id, *rule.Id))
+		}
+		objects.Policies = append(objects.Policies, policy)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return objects, nil
+}
+
+// importDir decodes every file below dir/kindDir, in a stable order. With domains set the
+// first directory level is the domain id.
+func importDir(dir, kindDir string, domains bool, decode func(file, domain string, fields map[string]any, format Format) error) error {
+	root := filepath.Join(dir, kindDir)
+	var files []string
+
+	err := filepath.WalkDir(root, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && file == root {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		if _, err := FormatForExtension(filepath.Ext(file)); err != nil {
+			return nil
+		}
+		files = append(files, file)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(files)
+
+	for _, file := range files {
+		domain := DefaultDomainId
+		if domains {
+			relative, err := filepath.Rel(root, file)
+			if err != nil {
+				return err
+			}
+			parts := strings.Split(filepath.ToSlash(relative), "/")
+			if len(parts) != 2 {
+				return fmt.Errorf("%s: expected %s/<domain>/<id> layout", file, kindDir)
+			}
+			domain, err = url.PathUnescape(parts[0])
+			if err != nil {
+				return fmt.Errorf("%s: invalid domain directory: %w", file, err)
+			}
+		}
+
+		format, _ := FormatForExtension(filepath.Ext(file))
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		fields, err := format.Unmarshal(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+
+		err = decode(file, domain, fields, format)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+	}
+
+	return nil
+}
+
+// decodeImported converts the decoded fields of a file into an object through its json representation,
+// so the json tags and the custom unmarshalers of the expression and service entry wrappers apply.
+// Fields the object doesn't have, e.g. misspelled ones, are an error.
+func decodeImported(fields map[string]any, format Format, out any) error {
+	encoded, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+
+	var generic any
+	err = decodeJSONNumbers(encoded, &generic)
+	if err != nil {
+		return err
+	}
+
+	if format == FormatHCL {
+		generic = importHCL(generic, reflect.TypeOf(out))
+		encoded, err = json.Marshal(generic)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = json.Unmarshal(encoded, out)
+	if err != nil {
+		return err
+	}
+
+	// the wrappers decode leniently, so compare with what was decoded instead of rejecting
+	// unknown fields while decoding
+	decoded, err := json.Marshal(out)
+	if err != nil {
+		return err
+	}
+	var decodedFields any
+	err = decodeJSONNumbers(decoded, &decodedFields)
+	if err != nil {
+		return err
+	}
+
+	unknown := unknownFields("", generic, decodedFields)
+	if len(unknown) > 0 {
+		return fmt.Errorf("unknown fields %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// unknownFields returns the fields set in input that are missing in decoded
+func unknownFields(field string, input, decoded any) []string {
+	var unknown []string
+	switch input := input.(type) {
+	case map[string]any:
+		decodedFields, _ := decoded.(map[string]any)
+		for key, value := range input {
+			decodedValue, ok := decodedFields[key]
+			if !ok {
+				if !isEmptyValue(value) {
+					unknown = append(unknown, joinField(field, key))
+				}
+				continue
+			}
+			unknown = append(unknown, unknownFields(joinField(field, key), value, decodedValue)...)
+		}
+	case []any:
+		decodedList, _ := decoded.([]any)
+		for i := range input {
+			if i < len(decodedList) {
+				unknown = append(unknown, unknownFields(fmt.Sprintf("%s[%d]", field, i), input[i], decodedList[i])...)
+			}
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// isEmptyValue reports whether a decoded value is the zero value of its type, which a
+// field left out by omitempty decodes from as well
+func isEmptyValue(value any) bool {
+	switch value := value.(type) {
+	case nil:
+		return true
+	case string:
+		return value == ""
+	case bool:
+		return !value
+	case json.Number:
+		f, err := value.Float64()
+		return err == nil && f == 0
+	case []any:
+		return len(value) == 0
+	case map[string]any:
+		return len(value) == 0
+	}
+	return false
+}
+
+var (
+	expressionWrapperType   = reflect.TypeOf(DynamicExpressionWrapper{})
+	serviceEntryWrapperType = reflect.TypeOf(DynamicServiceEntryWrapper{})
+)
+
+// importHCL unwraps the single element lists HCL decodes nested objects into, wherever the
+// Go type at that position is an object rather than a list
+func importHCL(value any, typ reflect.Type) any {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	switch typ {
+	case expressionWrapperType, serviceEntryWrapperType:
+		fields, ok := unwrapHCLObject(value).(map[string]any)
+		if !ok {
+			return value
+		}
+		resourceType, _ := fields["resource_type"].(string)
+		if typ == expressionWrapperType {
+			if newExpression, ok := DynamicExpressionMap[resourceType]; ok {
+				return importHCL(fields, reflect.TypeOf(newExpression()))
+			}
+		} else if newServiceEntry, ok := DynamicServiceEntryMap[resourceType]; ok {
+			return importHCL(fields, reflect.TypeOf(newServiceEntry()))
+		}
+		return fields
+	}
+
+	switch typ.Kind() {
+	case reflect.Struct:
+		fields, ok := unwrapHCLObject(value).(map[string]any)
+		if !ok {
+			return value
+		}
+		for _, field := range reflect.VisibleFields(typ) {
+			if field.Anonymous || !field.IsExported() {
+				continue
+			}
+			name := jsonFieldName(field)
+			if fieldValue, ok := fields[name]; ok {
+				fields[name] = importHCL(fieldValue, field.Type)
+			}
+		}
+		return fields
+	case reflect.Slice:
+		list, ok := value.([]any)
+		if !ok {
+			return value
+		}
+		for i := range list {
+			list[i] = importHCL(list[i], typ.Elem())
+		}
+		return list
+	case reflect.Map:
+		fields, ok := unwrapHCLObject(value).(map[string]any)
+		if !ok {
+			return value
+		}
+		for key, fieldValue := range fields {
+			fields[key] = importHCL(fieldValue, typ.Elem())
+		}
+		return fields
+	}
+
+	return value
+}
+
+func unwrapHCLObject(value any) any {
+	if list, ok := value.([]any); ok && len(list) == 1 {
+		if _, ok := list[0].(map[string]any); ok {
+			return list[0]
+		}
+	}
+	return value
+}
+
+func jsonFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
@@ -0,0 +1,151 @@
+package gonsx
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func exportTestObjects(t *testing.T) *ObjectSet {
+	t.Helper()
+
+	expression, err := NewExpressionBuilder().
+		Match(TagEqualsCondition(MemberTypeVirtualMachine, "app", "web")).
+		OrNested(NewExpressionBuilder().
+			Match(NameStartsWithCondition(MemberTypeVirtualMachine, "web-")).
+			And(NameEndsWithCondition(MemberTypeVirtualMachine, "-prd"))).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := testGroup("app", "app servers")
+	app.Expression = expression
+
+	// single element lists, which HCL can't tell from nested objects
+	web := testGroup("web", "web servers")
+	web.Expression = []DynamicExpressionWrapper{{Expression: IPAddressExpression("10.0.1.0/24")}}
+	web.Tags = []Tag{{Scope: "owner", Tag: "web-team"}}
+
+	entry := &L4PortSetServiceEntry{L4Protocol: "TCP", DestinationPorts: []string{"8443"}}
+	entry.Id = stringPointer("tcp-8443")
+	entry.ResourceType = stringPointer("L4PortSetServiceEntry")
+	service := testService("https-alt")
+	service.ServiceEntries = []DynamicServiceEntryWrapper{{ServiceEntry: entry}}
+
+	rule := testRule("allow-web", "ALLOW", 2147483647)
+	rule.DestinationGroups = []string{GroupPath(DefaultDomainId, "web")}
+	rule.Services = []string{ServicePath("https-alt")}
+	policy := testPolicy("web", "Application", 10, rule)
+
+	return &ObjectSet{Groups: []Group{app, web}, Services: []Service{service}, Policies: []SecurityPolicy{policy}}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	for _, format := range []Format{FormatJSON, FormatYAML, FormatTOML, FormatHCL} {
+		t.Run(format.Extension(), func(t *testing.T) {
+			objects := exportTestObjects(t)
+			dir := t.TempDir()
+
+			err := ExportObjectSet(objects, dir, ExportOptions{Format: format})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			imported, err := Import(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(imported.Groups) != len(objects.Groups) || len(imported.Services) != len(objects.Services) || len(imported.Policies) != len(objects.Policies) {
+				t.Fatalf("imported %d groups, %d services and %d policies, want %d, %d and %d",
+					len(imported.Groups), len(imported.Services), len(imported.Policies),
+					len(objects.Groups), len(objects.Services), len(objects.Policies))
+			}
+
+			check := func(original, imported any) {
+				changes, err := compareObjects(original, imported, true)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, change := range changes {
+					t.Errorf("changed by the round trip: %s", change)
+				}
+			}
+			for i := range objects.Groups {
+				check(&objects.Groups[i], &imported.Groups[i])
+			}
+			check(&objects.Services[0], &imported.Services[0])
+			check(&objects.Policies[0], &imported.Policies[0])
+
+			if got, want := *imported.Policies[0].Path, SecurityPolicyPath(DefaultDomainId, "web"); got != want {
+				t.Errorf("imported policy path %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestImportRejectsUnknownFields(t *testing.T) {
+	files := map[string]string{
+		"misspelled field": `{"id": "web", "dispaly_name": "web"}`,
+		"misspelled field in an expression": `{"id": "web", "expression": [
+			{"resource_type": "Condition", "member_type": "VirtualMachine", "key": "Name", "operator": "EQUALS", "valeu": "web"}
+		]}`,
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTestFile(t, filepath.Join(dir, exportGroupsDir, DefaultDomainId, "web.json"), content)
+
+			_, err := Import(dir)
+			if err == nil || !strings.Contains(err.Error(), "unknown fields") {
+				t.Errorf("Import error = %v, want unknown fields", err)
+			}
+		})
+	}
+}
+
+func TestImportKeepsIntegers(t *testing.T) {
+	dir := t.TempDir()
+	// 2^53 + 1 can't be represented as a float64
+	writeTestFile(t, filepath.Join(dir, exportGroupsDir, DefaultDomainId, "web.json"), `{"id": "web", "_create_time": 9007199254740993}`)
+
+	imported, err := Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := *imported.Groups[0].CreateTime; got != 9007199254740993 {
+		t.Errorf("_create_time = %d, want 9007199254740993", got)
+	}
+}
+
+func writeTestFile(t *testing.T, file, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImportHCLObjectField(t *testing.T) {
+	dir := t.TempDir()
+	// HCL decodes the _self block into a list with a single object
+	writeTestFile(t, filepath.Join(dir, exportGroupsDir, DefaultDomainId, "web.hcl"), `
+id = "web"
+
+_self {
+  href = "/infra/domains/default/groups/web"
+}
+`)
+
+	imported, err := Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if self := imported.Groups[0].Self; self == nil || self.Href != "/infra/domains/default/groups/web" {
+		t.Errorf("_self = %+v, want the href of the group", self)
+	}
+}
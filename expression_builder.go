@@ -0,0 +1,210 @@
+package gonsx
+
+import (
+	"fmt"
+)
+
+const (
+	MemberTypeVirtualMachine = "VirtualMachine"
+	MemberTypeSegment        = "Segment"
+	MemberTypeSegmentPort    = "SegmentPort"
+	MemberTypeIPSet          = "IPSet"
+)
+
+func stringPointer(s string) *string {
+	return &s
+}
+
+func newExpression(resourceType string) Expression {
+	return Expression{BaseNsxPolicyApiResource{ResourceType: stringPointer(resourceType)}}
+}
+
+// ConditionExpression creates a Condition on a key of a member type, e.g. Name STARTSWITH prd-
+func ConditionExpression(memberType, key, operator, value string) *ExpressionCondition {
+	return &ExpressionCondition{
+		Expression: newExpression("Condition"),
+		MemberType: stringPointer(memberType),
+		Key:        stringPointer(key),
+		Operator:   stringPointer(operator),
+		Value:      stringPointer(value),
+	}
+}
+
+// TagEqualsCondition matches members tagged with scope|tag, an empty scope matches the tag in any scope
+func TagEqualsCondition(memberType, scope, tag string) *ExpressionCondition {
+	return ConditionExpression(memberType, "Tag", "EQUALS", scope+"|"+tag)
+}
+
+// NameEqualsCondition matches members by their exact name
+func NameEqualsCondition(memberType, name string) *ExpressionCondition {
+	return ConditionExpression(memberType, "Name", "EQUALS", name)
+}
+
+// NameStartsWithCondition matches members whose name starts with prefix
+func NameStartsWithCondition(memberType, prefix string) *ExpressionCondition {
+	return ConditionExpression(memberType, "Name", "STARTSWITH", prefix)
+}
+
+// NameEndsWithCondition matches members whose name ends with suffix
+func NameEndsWithCondition(memberType, suffix string) *ExpressionCondition {
+	return ConditionExpression(memberType, "Name", "ENDSWITH", suffix)
+}
+
+// NameContainsCondition matches members whose name contains value
+func NameContainsCondition(memberType, value string) *ExpressionCondition {
+	return ConditionExpression(memberType, "Name", "CONTAINS", value)
+}
+
+// IPAddressExpression matches IP addresses, CIDRs and ranges
+func IPAddressExpression(ipAddresses ...string) *ExpressionIPAddress {
+	return &ExpressionIPAddress{
+		Expression:  newExpression("IPAddressExpression"),
+		IpAddresses: ipAddresses,
+	}
+}
+
+// MACAddressExpression matches MAC addresses
+func MACAddressExpression(macAddresses ...string) *ExpressionMACAddress {
+	return &ExpressionMACAddress{
+		Expression:   newExpression("MACAddressExpression"),
+		MacAddresses: macAddresses,
+	}
+}
+
+// PathExpression matches objects, including other groups, by their policy path
+func PathExpression(paths ...string) *ExpressionPath {
+	return &ExpressionPath{
+		Expression: newExpression("PathExpression"),
+		Paths:      paths,
+	}
+}
+
+// ExternalIDExpression matches members of a member type by their external id
+func ExternalIDExpression(memberType string, externalIds ...string) *ExpressionExternalID {
+	return &ExpressionExternalID{
+		Expression:     newExpression("ExternalIDExpression"),
+		ExternalIdType: stringPointer(memberType),
+		ExternalIds:    externalIds,
+	}
+}
+
+// NestedExpression turns the expression built by b into a single NestedExpression
+func NestedExpression(b *ExpressionBuilder) (DynamicExpression, error) {
+	expressions, err := b.build()
+	if err != nil {
+		return nil, err
+	}
+	return &ExpressionNested{Expression: newExpression("NestedExpression"), Expressions: expressions}, nil
+}
+
+// ExpressionBuilder builds a Group.Expression list, inserting the conjunction operators
+// between the expressions. AND binds stronger than OR.
+//
+//	expression, err := NewExpressionBuilder().
+//		Match(TagEqualsCondition(MemberTypeVirtualMachine, "app", "web")).
+//		And(NameStartsWithCondition(MemberTypeVirtualMachine, "prd-")).
+//		OrNested(NewExpressionBuilder().
+//			Match(TagEqualsCondition(MemberTypeVirtualMachine, "env", "lab")).
+//			And(NameContainsCondition(MemberTypeVirtualMachine, "db"))).
+//		Build()
+type ExpressionBuilder struct {
+	expressions []DynamicExpressionWrapper
+	err         error
+}
+
+// NewExpressionBuilder creates an empty builder
+func NewExpressionBuilder() *ExpressionBuilder {
+	return &ExpressionBuilder{}
+}
+
+// Match sets the first expression
+func (b *ExpressionBuilder) Match(expression DynamicExpression) *ExpressionBuilder {
+	if len(b.expressions) > 0 {
+		b.setErr(fmt.Errorf("the first expression is already set, use And or Or to add more"))
+		return b
+	}
+	return b.add(expression)
+}
+
+// And adds an expression that must match as well
+func (b *ExpressionBuilder) And(expression DynamicExpression) *ExpressionBuilder {
+	return b.conjunction("AND").add(expression)
+}
+
+// Or adds an alternative expression
+func (b *ExpressionBuilder) Or(expression DynamicExpression) *ExpressionBuilder {
+	return b.conjunction("OR").add(expression)
+}
+
+// MatchNested sets the first expression to the expression built by nested
+func (b *ExpressionBuilder) MatchNested(nested *ExpressionBuilder) *ExpressionBuilder {
+	return b.addNested(nested, b.Match)
+}
+
+// AndNested adds the expression built by nested, which must match as well
+func (b *ExpressionBuilder) AndNested(nested *ExpressionBuilder) *ExpressionBuilder {
+	return b.addNested(nested, b.And)
+}
+
+// OrNested adds the expression built by nested as an alternative
+func (b *ExpressionBuilder) OrNested(nested *ExpressionBuilder) *ExpressionBuilder {
+	return b.addNested(nested, b.Or)
+}
+
+func (b *ExpressionBuilder) addNested(nested *ExpressionBuilder, add func(DynamicExpression) *ExpressionBuilder) *ExpressionBuilder {
+	expression, err := NestedExpression(nested)
+	if err != nil {
+		b.setErr(err)
+		return b
+	}
+	return add(expression)
+}
+
+func (b *ExpressionBuilder) conjunction(operator string) *ExpressionBuilder {
+	if len(b.expressions) == 0 {
+		b.setErr(fmt.Errorf("%s needs a preceding expression, use Match first", operator))
+		return b
+	}
+	b.expressions = append(b.expressions, DynamicExpressionWrapper{&ExpressionConjunctionOperator{
+		Expression:          newExpression("ConjunctionOperator"),
+		ConjunctionOperator: stringPointer(operator),
+	}})
+	return b
+}
+
+func (b *ExpressionBuilder) add(expression DynamicExpression) *ExpressionBuilder {
+	b.expressions = append(b.expressions, DynamicExpressionWrapper{expression})
+	return b
+}
+
+func (b *ExpressionBuilder) setErr(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+func (b *ExpressionBuilder) build() ([]DynamicExpressionWrapper, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if len(b.expressions) == 0 {
+		return nil, fmt.Errorf("expression is empty, use Match to add an expression")
+	}
+	return b.expressions, nil
+}
+
+// Build returns the expression list, validated against the NSX constraints like Group.Validate
+func (b *ExpressionBuilder) Build() ([]DynamicExpressionWrapper, error) {
+	expressions, err := b.build()
+	if err != nil {
+		return nil, err
+	}
+
+	group := Group{Expression: expressions}
+	err = group.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return expressions, nil
+}
@@ -0,0 +1,413 @@
+package gonsx
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The expression DSL is a textual form of Group.Expression, meant for reviewing and diffing
+// group criteria:
+//
+//	VirtualMachine.Tag == "app|web" AND (VirtualMachine.Name STARTSWITH "prd-" OR VirtualMachine.Name STARTSWITH "stg-")
+//	OR IP ["10.0.0.0/24", "10.0.1.5"]
+//
+// Conditions are written as MemberType.Key OPERATOR "value", with == and != for EQUALS and
+// NOTEQUALS, and an optional WITH SCOPE NOTEQUALS for the scope operator of tag conditions.
+// A condition without an operator, as NSX allows for Segment and SegmentPort tags, is written
+// without one: Segment.Tag "scope|tag".
+// IP, MAC and PATH lists, and EXTERNALID MemberType lists, are written in brackets.
+// Parentheses create a NestedExpression. AND and OR map to conjunction operators, the list
+// is kept flat exactly like NSX stores it, so parsing a printed expression yields it back.
+
+var dslOperators = map[string]string{
+	"==":         "EQUALS",
+	"!=":         "NOTEQUALS",
+	"CONTAINS":   "CONTAINS",
+	"STARTSWITH": "STARTSWITH",
+	"ENDSWITH":   "ENDSWITH",
+	"IN":         "IN",
+	"NOTIN":      "NOTIN",
+	"MATCHES":    "MATCHES",
+}
+
+// FormatExpression prints an expression list in the expression DSL
+func FormatExpression(expressions []DynamicExpressionWrapper) (string, error) {
+	parts := make([]string, 0, len(expressions))
+
+	for i, wrapper := range expressions {
+		part, err := formatExpression(wrapper.Expression)
+		if err != nil {
+			return "", fmt.Errorf("expression %d: %w", i, err)
+		}
+		parts = append(parts, part)
+	}
+
+	return strings.Join(parts, " "), nil
+}
+
+func formatExpression(expression DynamicExpression) (string, error) {
+	switch expression := expression.(type) {
+	case *ExpressionConjunctionOperator:
+		if expression.ConjunctionOperator == nil {
+			return "", fmt.Errorf("conjunction operator is empty")
+		}
+		return *expression.ConjunctionOperator, nil
+	case *ExpressionCondition:
+		return formatCondition(expression)
+	case *ExpressionIPAddress:
+		return "IP " + formatList(expression.IpAddresses), nil
+	case *ExpressionMACAddress:
+		return "MAC " + formatList(expression.MacAddresses), nil
+	case *ExpressionPath:
+		return "PATH " + formatList(expression.Paths), nil
+	case *ExpressionExternalID:
+		if expression.ExternalIdType == nil {
+			return "", fmt.Errorf("external id expression has no member type")
+		}
+		return fmt.Sprintf("EXTERNALID %s %s", *expression.ExternalIdType, formatList(expression.ExternalIds)), nil
+	case *ExpressionNested:
+		nested, err := FormatExpression(expression.Expressions)
+		if err != nil {
+			return "", err
+		}
+		return "(" + nested + ")", nil
+	}
+	return "", fmt.Errorf("%s can't be written in the expression DSL", expressionTypeName(expression))
+}
+
+func formatCondition(condition *ExpressionCondition) (string, error) {
+	if condition.MemberType == nil || condition.Key == nil || condition.Value == nil {
+		return "", fmt.Errorf("condition requires a member type, key and value")
+	}
+
+	formatted := fmt.Sprintf("%s.%s", *condition.MemberType, *condition.Key)
+
+	// a condition without an operator is written without one, NSX treats it differently per member type
+	if condition.Operator != nil {
+		operator := ""
+		for symbol, name := range dslOperators {
+			if name == *condition.Operator {
+				operator = symbol
+			}
+		}
+		if operator == "" {
+			return "", fmt.Errorf("unknown operator %q", *condition.Operator)
+		}
+		formatted += " " + operator
+	}
+
+	formatted += " " + strconv.Quote(*condition.Value)
+	if condition.ScopeOperator != nil {
+		formatted += " WITH SCOPE " + *condition.ScopeOperator
+	}
+
+	return formatted, nil
+}
+
+func formatList(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, value := range values {
+		quoted = append(quoted, strconv.Quote(value))
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// ParseExpression parses the expression DSL into an expression list for Group.Expression
+func ParseExpression(input string) ([]DynamicExpressionWrapper, error) {
+	tokens, err := tokenizeDSL(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &dslParser{tokens: tokens}
+	expressions, err := p.parseList()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.done() {
+		return nil, p.errorf("unexpected %s", p.peek().text)
+	}
+
+	return expressions, nil
+}
+
+type dslTokenKind int
+
+const (
+	dslWord dslTokenKind = iota
+	dslString
+	dslSymbol
+)
+
+type dslToken struct {
+	kind dslTokenKind
+	text string
+	pos  int
+}
+
+func tokenizeDSL(input string) ([]dslToken, error) {
+	var tokens []dslToken
+
+	for i := 0; i < len(input); {
+		c := rune(input[i])
+
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"':
+			end := i + 1
+			for end < len(input) && input[end] != '"' {
+				if input[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(input) {
+				return nil, fmt.Errorf("position %d: unterminated string", i)
+			}
+			value, err := strconv.Unquote(input[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("position %d: invalid string: %v", i, err)
+			}
+			tokens = append(tokens, dslToken{dslString, value, i})
+			i = end + 1
+		case strings.HasPrefix(input[i:], "==") || strings.HasPrefix(input[i:], "!="):
+			tokens = append(tokens, dslToken{dslSymbol, input[i : i+2], i})
+			i += 2
+		case strings.ContainsRune("()[],", c):
+			tokens = append(tokens, dslToken{dslSymbol, string(c), i})
+			i++
+		case unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_' || c == '.':
+			end := i
+			for end < len(input) {
+				r := rune(input[end])
+				if !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.') {
+					break
+				}
+				end++
+			}
+			tokens = append(tokens, dslToken{dslWord, input[i:end], i})
+			i = end
+		default:
+			return nil, fmt.Errorf("position %d: unexpected character %q", i, c)
+		}
+	}
+
+	return tokens, nil
+}
+
+type dslParser struct {
+	tokens []dslToken
+	next   int
+}
+
+func (p *dslParser) done() bool {
+	return p.next >= len(p.tokens)
+}
+
+func (p *dslParser) peek() dslToken {
+	if p.done() {
+		return dslToken{dslSymbol, "end of input", -1}
+	}
+	return p.tokens[p.next]
+}
+
+func (p *dslParser) errorf(format string, a ...any) error {
+	token := p.peek()
+	if token.pos < 0 {
+		return fmt.Errorf("at end of input: "+format, a...)
+	}
+	return fmt.Errorf("position %d: "+format, append([]any{token.pos}, a...)...)
+}
+
+// isWord reports whether the next token is the keyword, case insensitive
+func (p *dslParser) isWord(word string) bool {
+	token := p.peek()
+	return token.kind == dslWord && strings.EqualFold(token.text, word)
+}
+
+func (p *dslParser) expectSymbol(symbol string) error {
+	token := p.peek()
+	if token.kind != dslSymbol || token.text != symbol {
+		return p.errorf("expected %s, got %s", symbol, token.text)
+	}
+	p.next++
+	return nil
+}
+
+func (p *dslParser) expectString() (string, error) {
+	token := p.peek()
+	if token.kind != dslString {
+		return "", p.errorf("expected a quoted string, got %s", token.text)
+	}
+	p.next++
+	return token.text, nil
+}
+
+func (p *dslParser) expectWord() (string, error) {
+	token := p.peek()
+	if token.kind != dslWord {
+		return "", p.errorf("expected a word, got %s", token.text)
+	}
+	p.next++
+	return token.text, nil
+}
+
+// parseList parses expressions separated by AND and OR
+func (p *dslParser) parseList() ([]DynamicExpressionWrapper, error) {
+	var expressions []DynamicExpressionWrapper
+
+	for {
+		expression, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		expressions = append(expressions, DynamicExpressionWrapper{expression})
+
+		var operator string
+		switch {
+		case p.isWord("AND"):
+			operator = "AND"
+		case p.isWord("OR"):
+			operator = "OR"
+		default:
+			return expressions, nil
+		}
+		p.next++
+
+		expressions = append(expressions, DynamicExpressionWrapper{&ExpressionConjunctionOperator{
+			Expression:          newExpression("ConjunctionOperator"),
+			ConjunctionOperator: stringPointer(operator),
+		}})
+	}
+}
+
+func (p *dslParser) parseExpression() (DynamicExpression, error) {
+	token := p.peek()
+
+	switch {
+	case token.kind == dslSymbol && token.text == "(":
+		p.next++
+		expressions, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		err = p.expectSymbol(")")
+		if err != nil {
+			return nil, err
+		}
+		return &ExpressionNested{Expression: newExpression("NestedExpression"), Expressions: expressions}, nil
+	case p.isWord("IP"):
+		p.next++
+		values, err := p.parseStringList()
+		if err != nil {
+			return nil, err
+		}
+		return IPAddressExpression(values...), nil
+	case p.isWord("MAC"):
+		p.next++
+		values, err := p.parseStringList()
+		if err != nil {
+			return nil, err
+		}
+		return MACAddressExpression(values...), nil
+	case p.isWord("PATH"):
+		p.next++
+		values, err := p.parseStringList()
+		if err != nil {
+			return nil, err
+		}
+		return PathExpression(values...), nil
+	case p.isWord("EXTERNALID"):
+		p.next++
+		memberType, err := p.expectWord()
+		if err != nil {
+			return nil, err
+		}
+		values, err := p.parseStringList()
+		if err != nil {
+			return nil, err
+		}
+		return ExternalIDExpression(memberType, values...), nil
+	case token.kind == dslWord:
+		return p.parseCondition()
+	}
+
+	return nil, p.errorf("expected an expression, got %s", token.text)
+}
+
+// parseCondition parses MemberType.Key OPERATOR "value" [WITH SCOPE OPERATOR]
+func (p *dslParser) parseCondition() (DynamicExpression, error) {
+	field, err := p.expectWord()
+	if err != nil {
+		return nil, err
+	}
+
+	memberType, key, found := strings.Cut(field, ".")
+	if !found || memberType == "" || key == "" {
+		p.next--
+		return nil, p.errorf("expected MemberType.Key, got %s", field)
+	}
+
+	// the operator is optional, a condition without one keeps Operator unset
+	var operator *string
+	if operatorToken := p.peek(); operatorToken.kind != dslString {
+		name, ok := dslOperators[strings.ToUpper(operatorToken.text)]
+		if !ok {
+			return nil, p.errorf("expected an operator or a quoted value, got %s", operatorToken.text)
+		}
+		operator = &name
+		p.next++
+	}
+
+	value, err := p.expectString()
+	if err != nil {
+		return nil, err
+	}
+
+	condition := ConditionExpression(memberType, key, "", value)
+	condition.Operator = operator
+
+	if p.isWord("WITH") {
+		p.next++
+		if !p.isWord("SCOPE") {
+			return nil, p.errorf("expected SCOPE after WITH")
+		}
+		p.next++
+		scopeOperator, err := p.expectWord()
+		if err != nil {
+			return nil, err
+		}
+		scopeOperator = strings.ToUpper(scopeOperator)
+		condition.ScopeOperator = &scopeOperator
+	}
+
+	return condition, nil
+}
+
+// parseStringList parses ["a", "b", ...]
+func (p *dslParser) parseStringList() ([]string, error) {
+	err := p.expectSymbol("[")
+	if err != nil {
+		return nil, err
+	}
+
+	var values []string
+	for {
+		value, err := p.expectString()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+
+		token := p.peek()
+		if token.kind == dslSymbol && token.text == "," {
+			p.next++
+			continue
+		}
+		return values, p.expectSymbol("]")
+	}
+}
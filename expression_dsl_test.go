@@ -0,0 +1,88 @@
+package gonsx
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestExpressionRoundTrip prints the expressions of every fixture in the DSL, parses them back
+// and checks the json NSX would receive is unchanged
+func TestExpressionRoundTrip(t *testing.T) {
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "expressions", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatal("no fixtures found")
+	}
+
+	for _, fixture := range fixtures {
+		t.Run(filepath.Base(fixture), func(t *testing.T) {
+			data, err := os.ReadFile(fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var expressions []DynamicExpressionWrapper
+			err = json.Unmarshal(data, &expressions)
+			if err != nil {
+				t.Fatalf("error decoding fixture: %v", err)
+			}
+
+			printed, err := FormatExpression(expressions)
+			if err != nil {
+				t.Fatalf("FormatExpression: %v", err)
+			}
+
+			parsed, err := ParseExpression(printed)
+			if err != nil {
+				t.Fatalf("ParseExpression(%s): %v", printed, err)
+			}
+
+			encoded, err := json.Marshal(parsed)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var want, got any
+			if err := json.Unmarshal(data, &want); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal(encoded, &got); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(want, got) {
+				t.Errorf("round trip through %s changed the expression\nwant: %s\ngot:  %s", printed, data, encoded)
+			}
+
+			reprinted, err := FormatExpression(parsed)
+			if err != nil {
+				t.Fatalf("FormatExpression of the parsed expression: %v", err)
+			}
+			if reprinted != printed {
+				t.Errorf("printed %q, reprinted %q", printed, reprinted)
+			}
+		})
+	}
+}
+
+func TestParseExpressionErrors(t *testing.T) {
+	inputs := []string{
+		`VirtualMachine.Name`,
+		`VirtualMachine.Name BETWEEN "a"`,
+		`VirtualMachine.Name == "a" AND`,
+		`(VirtualMachine.Name == "a"`,
+		`IP ["10.0.0.1"`,
+		`Name == "a"`,
+	}
+
+	for _, input := range inputs {
+		if _, err := ParseExpression(input); err == nil {
+			t.Errorf("ParseExpression(%s) succeeded, want an error", input)
+		}
+	}
+}
@@ -1,12 +1,15 @@
 package gonsx
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net/url"
 )
 
 const (
 	AllGroupsEndpoint = "/policy/api/v1/infra/domains/default/groups"
+	DefaultDomainId   = "default"
 )
 
 type Group struct {
@@ -30,6 +33,68 @@ func (g *Group) String() string {
 	return fmt.Sprintf(`Group: %s`, *g.DisplayName)
 }
 
+// GroupPath returns the policy path of a group in a domain
+func GroupPath(domainId, groupId string) string {
+	return fmt.Sprintf("/infra/domains/%s/groups/%s", url.PathEscape(domainId), url.PathEscape(groupId))
+}
+
+// policyPath returns the policy path of the group, falling back to the default domain
+// when the group was not retrieved from NSX
+func (g *Group) policyPath() string {
+	if g.Path != nil {
+		return *g.Path
+	}
+	return GroupPath(DefaultDomainId, *g.Id)
+}
+
+// GetGroup fetches a single group from a domain
+func GetGroup(ctx context.Context, nsxConfig *NSXClient, domainId, groupId string) (*Group, error) {
+	return getResource[Group](ctx, nsxConfig, GroupPath(domainId, groupId))
+}
+
+// ListGroups fetches all groups in a domain
+func ListGroups(ctx context.Context, nsxConfig *NSXClient, domainId string) ([]Group, error) {
+	return listResources[Group](ctx, nsxConfig, fmt.Sprintf("/infra/domains/%s/groups", url.PathEscape(domainId)))
+}
+
+// CreateGroup creates a new group in a domain, the group Id must be set.
+// Creating a group that already exists is rejected by NSX, as no revision is sent.
+func CreateGroup(ctx context.Context, nsxConfig *NSXClient, domainId string, group *Group) (*Group, error) {
+	if group.Id == nil {
+		return nil, fmt.Errorf("group id is required to create a group")
+	}
+	if group.Revision != nil {
+		return nil, fmt.Errorf("group %s has a revision, use UpdateGroup to modify an existing group", *group.Id)
+	}
+	return putResource(ctx, nsxConfig, GroupPath(domainId, *group.Id), group, nil)
+}
+
+// UpdateGroup replaces an existing group. The group Revision must be the revision last read
+// from NSX, if the group was modified since, a RevisionConflictError is returned.
+func UpdateGroup(ctx context.Context, nsxConfig *NSXClient, domainId string, group *Group) (*Group, error) {
+	if group.Id == nil {
+		return nil, fmt.Errorf("group id is required to update a group")
+	}
+	if group.Revision == nil {
+		return nil, fmt.Errorf("group %s has no revision, use CreateGroup to create a new group", *group.Id)
+	}
+	return putResource(ctx, nsxConfig, GroupPath(domainId, *group.Id), group, group.Revision)
+}
+
+// PatchGroup creates a group, or updates only the fields set on an existing group.
+// If the group Revision is set it is checked by NSX like with UpdateGroup.
+func PatchGroup(ctx context.Context, nsxConfig *NSXClient, domainId string, group *Group) error {
+	if group.Id == nil {
+		return fmt.Errorf("group id is required to patch a group")
+	}
+	return patchResource(ctx, nsxConfig, GroupPath(domainId, *group.Id), group, group.Revision)
+}
+
+// DeleteGroup deletes a group from a domain, deleting a group that does not exist is not an error
+func DeleteGroup(ctx context.Context, nsxConfig *NSXClient, domainId, groupId string) error {
+	return deleteResource(ctx, nsxConfig, GroupPath(domainId, groupId), nil)
+}
+
 type Expression struct {
 	BaseNsxPolicyApiResource
 }
@@ -70,6 +135,9 @@ var DynamicExpressionMap = map[string]func() DynamicExpression{
 	"IdentityGroupExpression": func() DynamicExpression {
 		return &ExpressionIdentityGroup{}
 	},
+	"NestedExpression": func() DynamicExpression {
+		return &ExpressionNested{}
+	},
 }
 
 // unmarshalJSON is a custom unmarshaler for DynamicExpressionWrapper
@@ -150,55 +218,73 @@ type ExpressionIdentityGroup struct {
 	IdentityGroups []IdentityGroupInfo `json:"identity_groups"`
 }
 
+// ExpressionNested groups an expression list, so it can be combined as a single expression
+type ExpressionNested struct {
+	Expression
+	// Expressions, following the same rules as Group.Expression
+	Expressions []DynamicExpressionWrapper `json:"expressions"`
+}
+
 type IdentityGroupInfo struct {
 	DistinguishedName string `json:"distinguished_name"`
 	DomainBaseDN      string `json:"domain_base_distinquished_name"`
 	Sid               string `json:"sid,omitempty"`
 }
 
+// GetMembers is kept for backward compatibility, use GetMembersWithContext
 func (g *Group) GetMembers(nsxConfig *NSXClient) ([]string, error) {
+	return g.GetMembersWithContext(context.Background(), nsxConfig)
+}
+
+// GetMembersWithContext is like GetMembers, but both member lookups are bound to ctx
+func (g *Group) GetMembersWithContext(ctx context.Context, nsxConfig *NSXClient) ([]string, error) {
 	members := []string{}
 
-	vmMembersChan := make(chan []string, 1)
-	ipMembersChan := make(chan []string, 1)
+	type memberResult struct {
+		members []string
+		err     error
+	}
+
+	vmMembersChan := make(chan memberResult, 1)
+	ipMembersChan := make(chan memberResult, 1)
 
 	go func() {
-		members, err := g.GetVmMembers(nsxConfig)
-		if err != nil {
-			vmMembersChan <- nil
-		}
-		vmMembersChan <- members
+		members, err := g.GetVmMembersWithContext(ctx, nsxConfig)
+		vmMembersChan <- memberResult{members, err}
 	}()
 
 	go func() {
-		members, err := g.GetIPAddressMembers(nsxConfig)
-		if err != nil {
-			ipMembersChan <- nil
-		}
-		ipMembersChan <- members
+		members, err := g.GetIPAddressMembersWithContext(ctx, nsxConfig)
+		ipMembersChan <- memberResult{members, err}
 	}()
 
-	var vmMembers []string = <-vmMembersChan
-	var ipMembers []string = <-ipMembersChan
+	vmMembers := <-vmMembersChan
+	ipMembers := <-ipMembersChan
 
-	if vmMembers == nil {
-		return nil, fmt.Errorf("error getting vm members")
+	if vmMembers.err != nil {
+		return nil, fmt.Errorf("error getting vm members: %w", vmMembers.err)
 	}
 
-	if ipMembers == nil {
-		return nil, fmt.Errorf("error getting ip members")
+	if ipMembers.err != nil {
+		return nil, fmt.Errorf("error getting ip members: %w", ipMembers.err)
 	}
 
-	members = append(members, vmMembers...)
-	members = append(members, ipMembers...)
+	members = append(members, vmMembers.members...)
+	members = append(members, ipMembers.members...)
 
 	return members, nil
 
 }
 
+// GetIPAddressMembers is kept for backward compatibility, use GetIPAddressMembersWithContext
 func (g *Group) GetIPAddressMembers(nsxConfig *NSXClient) ([]string, error) {
-	requestURI := fmt.Sprintf("https://%s/policy/api/v1/infra/domains/default/groups/%s/members/ip-addresses", nsxConfig.Hostname, *g.Id)
-	request, err := nsxConfig.NewRequest("GET", requestURI, nil)
+	return g.GetIPAddressMembersWithContext(context.Background(), nsxConfig)
+}
+
+// GetIPAddressMembersWithContext is like GetIPAddressMembers, but the request is bound to ctx
+func (g *Group) GetIPAddressMembersWithContext(ctx context.Context, nsxConfig *NSXClient) ([]string, error) {
+	requestURI := nsxConfig.policyURL(g.policyPath()+"/members/ip-addresses", nil)
+	request, err := nsxConfig.NewRequestWithContext(ctx, "GET", requestURI, nil)
 
 	if err != nil {
 		return nil, err
@@ -224,9 +310,15 @@ func (g *Group) GetIPAddressMembers(nsxConfig *NSXClient) ([]string, error) {
 	return results.Results, nil
 }
 
+// GetVmMembers is kept for backward compatibility, use GetVmMembersWithContext
 func (g *Group) GetVmMembers(nsxConfig *NSXClient) ([]string, error) {
-	requestURI := fmt.Sprintf("https://%s/policy/api/v1/infra/domains/default/groups/%s/members/virtual-machines", nsxConfig.Hostname, *g.Id)
-	request, err := nsxConfig.NewRequest("GET", requestURI, nil)
+	return g.GetVmMembersWithContext(context.Background(), nsxConfig)
+}
+
+// GetVmMembersWithContext is like GetVmMembers, but the request is bound to ctx
+func (g *Group) GetVmMembersWithContext(ctx context.Context, nsxConfig *NSXClient) ([]string, error) {
+	requestURI := nsxConfig.policyURL(g.policyPath()+"/members/virtual-machines", nil)
+	request, err := nsxConfig.NewRequestWithContext(ctx, "GET", requestURI, nil)
 
 	if err != nil {
 		return nil, err
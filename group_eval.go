@@ -0,0 +1,405 @@
+package gonsx
+
+import (
+	"fmt"
+	"strings"
+)
+
+// InventoryMember is an inventory object that can be a group member, e.g. a VM, Segment or SegmentPort
+type InventoryMember struct {
+	// Group member type, e.g. VirtualMachine, Segment or SegmentPort
+	MemberType string
+	// Policy path, or another identifier unique across the inventory
+	Path        string
+	DisplayName string
+	ExternalId  string
+	Tags        []Tag
+	// Guest OS name and computer name, only known for VMs
+	OSName       string
+	ComputerName string
+	// IP and MAC addresses of the object
+	IPAddresses  []string
+	MACAddresses []string
+	// Policy paths of the segments and segment ports the object is attached to. A group with
+	// a segment or segment port as member also contains the objects attached to it.
+	SegmentPaths []string
+}
+
+// VirtualMachineMember converts a VM into an inventory member
+func VirtualMachineMember(vm VirtualMachine) InventoryMember {
+	member := InventoryMember{
+		MemberType: "VirtualMachine",
+		ExternalId: vm.ExternalId,
+		Tags:       vm.Tags,
+	}
+	if vm.Path != nil {
+		member.Path = *vm.Path
+	} else {
+		member.Path = vm.ExternalId
+	}
+	if vm.DisplayName != nil {
+		member.DisplayName = *vm.DisplayName
+	}
+	if vm.GuestInfo != nil {
+		if vm.GuestInfo.OsName != nil {
+			member.OSName = *vm.GuestInfo.OsName
+		}
+		if vm.GuestInfo.ComputerName != nil {
+			member.ComputerName = *vm.GuestInfo.ComputerName
+		}
+	}
+	return member
+}
+
+// GroupMembership is the membership of a group as computed by an ExpressionEvaluator
+type GroupMembership struct {
+	// Inventory members, by path
+	Members map[string]InventoryMember
+	// IP addresses from IPAddressExpressions
+	IPAddresses map[string]bool
+	// MAC addresses from MACAddressExpressions
+	MACAddresses map[string]bool
+}
+
+func newGroupMembership() *GroupMembership {
+	return &GroupMembership{
+		Members:      map[string]InventoryMember{},
+		IPAddresses:  map[string]bool{},
+		MACAddresses: map[string]bool{},
+	}
+}
+
+func (m *GroupMembership) union(other *GroupMembership) *GroupMembership {
+	result := newGroupMembership()
+	for _, membership := range []*GroupMembership{m, other} {
+		for path, member := range membership.Members {
+			result.Members[path] = member
+		}
+		for ip := range membership.IPAddresses {
+			result.IPAddresses[ip] = true
+		}
+		for mac := range membership.MACAddresses {
+			result.MACAddresses[mac] = true
+		}
+	}
+	return result
+}
+
+func (m *GroupMembership) intersect(other *GroupMembership) *GroupMembership {
+	result := newGroupMembership()
+	for path, member := range m.Members {
+		if _, ok := other.Members[path]; ok {
+			result.Members[path] = member
+		}
+	}
+	for ip := range m.IPAddresses {
+		if other.IPAddresses[ip] {
+			result.IPAddresses[ip] = true
+		}
+	}
+	for mac := range m.MACAddresses {
+		if other.MACAddresses[mac] {
+			result.MACAddresses[mac] = true
+		}
+	}
+	return result
+}
+
+// ExpressionEvaluator computes group membership locally from an inventory snapshot, so the
+// impact of a tag or group change can be previewed before it is applied on NSX. Comparisons
+// of names and tags are case insensitive.
+type ExpressionEvaluator struct {
+	// Objects that can be members of a group
+	Inventory []InventoryMember
+	// Groups that can be referenced by a PathExpression, by path
+	Groups map[string]Group
+
+	cache    map[string]*GroupMembership
+	visiting map[string]bool
+}
+
+// NewExpressionEvaluator creates an evaluator over an inventory and the existing groups
+func NewExpressionEvaluator(inventory []InventoryMember, groups []Group) *ExpressionEvaluator {
+	evaluator := &ExpressionEvaluator{
+		Inventory: inventory,
+		Groups:    map[string]Group{},
+		cache:     map[string]*GroupMembership{},
+		visiting:  map[string]bool{},
+	}
+	for _, group := range groups {
+		if group.Path != nil {
+			evaluator.Groups[*group.Path] = group
+		}
+	}
+	return evaluator
+}
+
+// Evaluate computes the membership of a group from its Expression. Nested groups referenced
+// by a PathExpression are evaluated from their own expressions and cached.
+func (e *ExpressionEvaluator) Evaluate(group Group) (*GroupMembership, error) {
+	if e.cache == nil {
+		e.cache = map[string]*GroupMembership{}
+	}
+	if e.visiting == nil {
+		e.visiting = map[string]bool{}
+	}
+
+	path := ""
+	if group.Path != nil {
+		path = *group.Path
+		if membership, ok := e.cache[path]; ok {
+			return membership, nil
+		}
+		if e.visiting[path] {
+			return nil, fmt.Errorf("group %s is nested in itself", path)
+		}
+		e.visiting[path] = true
+		defer delete(e.visiting, path)
+	}
+
+	membership, err := e.evaluateExpressions(group.Expression)
+	if err != nil {
+		if path != "" {
+			return nil, fmt.Errorf("error evaluating group %s: %w", path, err)
+		}
+		return nil, err
+	}
+
+	if path != "" {
+		e.cache[path] = membership
+	}
+
+	return membership, nil
+}
+
+// evaluateExpressions evaluates an expression list, AND binds stronger than OR
+func (e *ExpressionEvaluator) evaluateExpressions(expressions []DynamicExpressionWrapper) (*GroupMembership, error) {
+	if len(expressions) == 0 {
+		return newGroupMembership(), nil
+	}
+	if len(expressions)%2 == 0 {
+		return nil, fmt.Errorf("expression list must have an odd length, got %d", len(expressions))
+	}
+
+	var result, term *GroupMembership
+
+	for i, wrapper := range expressions {
+		if i%2 == 1 {
+			conjunction, ok := wrapper.Expression.(*ExpressionConjunctionOperator)
+			if !ok || conjunction.ConjunctionOperator == nil {
+				return nil, fmt.Errorf("expression %d must be a conjunction operator", i)
+			}
+			switch *conjunction.ConjunctionOperator {
+			case "AND":
+			case "OR":
+				result = unionOf(result, term)
+				term = nil
+			default:
+				return nil, fmt.Errorf("expression %d: unknown conjunction operator %q", i, *conjunction.ConjunctionOperator)
+			}
+			continue
+		}
+
+		membership, err := e.evaluateExpression(wrapper.Expression)
+		if err != nil {
+			return nil, fmt.Errorf("expression %d: %w", i, err)
+		}
+
+		if term == nil {
+			term = membership
+		} else {
+			term = term.intersect(membership)
+		}
+	}
+
+	return unionOf(result, term), nil
+}
+
+func unionOf(a, b *GroupMembership) *GroupMembership {
+	switch {
+	case a == nil && b == nil:
+		return newGroupMembership()
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	}
+	return a.union(b)
+}
+
+func (e *ExpressionEvaluator) evaluateExpression(expression DynamicExpression) (*GroupMembership, error) {
+	membership := newGroupMembership()
+
+	switch expression := expression.(type) {
+	case *ExpressionCondition:
+		for _, member := range e.Inventory {
+			matched, err := conditionMatches(expression, member)
+			if err != nil {
+				return nil, err
+			}
+			if matched {
+				membership.Members[member.Path] = member
+			}
+		}
+	case *ExpressionIPAddress:
+		for _, ip := range expression.IpAddresses {
+			membership.IPAddresses[ip] = true
+		}
+	case *ExpressionMACAddress:
+		for _, mac := range expression.MacAddresses {
+			membership.MACAddresses[strings.ToLower(mac)] = true
+		}
+	case *ExpressionExternalID:
+		for _, member := range e.Inventory {
+			if expression.ExternalIdType != nil && member.MemberType != *expression.ExternalIdType {
+				continue
+			}
+			for _, externalId := range expression.ExternalIds {
+				if member.ExternalId == externalId {
+					membership.Members[member.Path] = member
+				}
+			}
+		}
+	case *ExpressionPath:
+		for _, path := range expression.Paths {
+			if group, ok := e.Groups[path]; ok {
+				nested, err := e.Evaluate(group)
+				if err != nil {
+					return nil, err
+				}
+				membership = membership.union(nested)
+				continue
+			}
+			for _, member := range e.Inventory {
+				if member.Path == path {
+					membership.Members[member.Path] = member
+				}
+			}
+		}
+	case *ExpressionNested:
+		return e.evaluateExpressions(expression.Expressions)
+	case *ExpressionIdentityGroup:
+		// identity groups are resolved against Active Directory, which is not in the inventory
+	default:
+		return nil, fmt.Errorf("unsupported expression type %T", expression)
+	}
+
+	e.addAttached(membership)
+
+	return membership, nil
+}
+
+// addAttached adds the inventory members attached to a segment or segment port of the
+// membership, repeated so a VM on a port of a member segment is added too
+func (e *ExpressionEvaluator) addAttached(membership *GroupMembership) {
+	for added := true; added; {
+		added = false
+		for _, member := range e.Inventory {
+			if _, ok := membership.Members[member.Path]; ok {
+				continue
+			}
+			for _, segmentPath := range member.SegmentPaths {
+				if _, ok := membership.Members[segmentPath]; ok {
+					membership.Members[member.Path] = member
+					added = true
+					break
+				}
+			}
+		}
+	}
+}
+
+// conditionMatches evaluates a Condition against a single inventory member
+func conditionMatches(condition *ExpressionCondition, member InventoryMember) (bool, error) {
+	if condition.MemberType != nil && *condition.MemberType != member.MemberType {
+		return false, nil
+	}
+	if condition.Key == nil || condition.Value == nil {
+		return false, fmt.Errorf("condition requires a key and a value")
+	}
+
+	operator := "EQUALS"
+	if condition.Operator != nil {
+		operator = *condition.Operator
+	}
+
+	switch *condition.Key {
+	case "Name":
+		return compareCondition(operator, member.DisplayName, *condition.Value)
+	case "OSName":
+		return compareCondition(operator, member.OSName, *condition.Value)
+	case "ComputerName":
+		return compareCondition(operator, member.ComputerName, *condition.Value)
+	case "Tag":
+		return tagConditionMatches(condition, operator, member.Tags)
+	}
+
+	return false, fmt.Errorf("unsupported condition key %q", *condition.Key)
+}
+
+// tagConditionMatches evaluates a Tag condition, its value is "scope|tag", where the operator
+// applies to the tag and the ScopeOperator to the scope
+func tagConditionMatches(condition *ExpressionCondition, operator string, tags []Tag) (bool, error) {
+	scope, tag, hasScope := strings.Cut(*condition.Value, "|")
+	if !hasScope {
+		tag, scope = scope, ""
+	}
+
+	scopeOperator := "EQUALS"
+	if condition.ScopeOperator != nil {
+		scopeOperator = *condition.ScopeOperator
+	}
+
+	// NOTEQUALS matches members without any tag equal to the value
+	if operator == "NOTEQUALS" {
+		equals := *condition
+		equalsOperator := "EQUALS"
+		equals.Operator = &equalsOperator
+		matched, err := tagConditionMatches(&equals, equalsOperator, tags)
+		return !matched, err
+	}
+
+	for _, memberTag := range tags {
+		if scope != "" {
+			matched, err := compareCondition(scopeOperator, memberTag.Scope, scope)
+			if err != nil {
+				return false, err
+			}
+			if !matched {
+				continue
+			}
+		}
+		if tag != "" {
+			matched, err := compareCondition(operator, memberTag.Tag, tag)
+			if err != nil {
+				return false, err
+			}
+			if !matched {
+				continue
+			}
+		}
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// compareCondition applies a condition operator, case insensitive
+func compareCondition(operator, actual, expected string) (bool, error) {
+	actual = strings.ToLower(actual)
+	expected = strings.ToLower(expected)
+
+	switch operator {
+	case "EQUALS":
+		return actual == expected, nil
+	case "NOTEQUALS":
+		return actual != expected, nil
+	case "CONTAINS":
+		return strings.Contains(actual, expected), nil
+	case "STARTSWITH":
+		return strings.HasPrefix(actual, expected), nil
+	case "ENDSWITH":
+		return strings.HasSuffix(actual, expected), nil
+	}
+	return false, fmt.Errorf("unsupported operator %q", operator)
+}
@@ -0,0 +1,73 @@
+package gonsx
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestEvaluateSegmentAttachments(t *testing.T) {
+	inventory := []InventoryMember{
+		{MemberType: "Segment", Path: "/infra/segments/web", Tags: []Tag{{Scope: "tier", Tag: "web"}}},
+		{MemberType: "Segment", Path: "/infra/segments/db"},
+		{MemberType: "SegmentPort", Path: "/infra/segments/web/ports/p1", SegmentPaths: []string{"/infra/segments/web"}},
+		{MemberType: "SegmentPort", Path: "/infra/segments/db/ports/p2", SegmentPaths: []string{"/infra/segments/db"}},
+		{MemberType: "VirtualMachine", Path: "vm-1", SegmentPaths: []string{"/infra/segments/web/ports/p1"}},
+		{MemberType: "VirtualMachine", Path: "vm-2", SegmentPaths: []string{"/infra/segments/db"}},
+		{MemberType: "VirtualMachine", Path: "vm-3"},
+	}
+
+	pointer := func(s string) *string { return &s }
+	segmentTag := &ExpressionCondition{
+		MemberType: pointer("Segment"),
+		Key:        pointer("Tag"),
+		Value:      pointer("tier|web"),
+	}
+
+	tests := []struct {
+		name       string
+		expression DynamicExpression
+		members    []string
+	}{
+		{
+			name:       "segment tag",
+			expression: segmentTag,
+			members:    []string{"/infra/segments/web", "/infra/segments/web/ports/p1", "vm-1"},
+		},
+		{
+			name:       "segment path",
+			expression: &ExpressionPath{Paths: []string{"/infra/segments/db"}},
+			members:    []string{"/infra/segments/db", "/infra/segments/db/ports/p2", "vm-2"},
+		},
+		{
+			name:       "segment port path",
+			expression: &ExpressionPath{Paths: []string{"/infra/segments/web/ports/p1"}},
+			members:    []string{"/infra/segments/web/ports/p1", "vm-1"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			group := Group{Expression: []DynamicExpressionWrapper{{Expression: test.expression}}}
+			membership, err := NewExpressionEvaluator(inventory, nil).Evaluate(group)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var members []string
+			for path := range membership.Members {
+				members = append(members, path)
+			}
+			sort.Strings(members)
+			sort.Strings(test.members)
+
+			if len(members) != len(test.members) {
+				t.Fatalf("members = %v, want %v", members, test.members)
+			}
+			for i := range members {
+				if members[i] != test.members[i] {
+					t.Fatalf("members = %v, want %v", members, test.members)
+				}
+			}
+		})
+	}
+}
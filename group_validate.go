@@ -0,0 +1,213 @@
+package gonsx
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// Maximum number of Condition and nested expressions in an expression list
+	MaxGroupConditions = 5
+	// Maximum number of IP addresses, MAC addresses, external ids and paths in an expression list
+	MaxGroupMembers = 500
+)
+
+// ValidationProblem is a single violated constraint, Field points at the offending value,
+// e.g. expression[2].value
+type ValidationProblem struct {
+	Field   string
+	Message string
+}
+
+// ValidationError lists every constraint a group violates
+type ValidationError struct {
+	Problems []ValidationProblem
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Problems))
+	for _, problem := range e.Problems {
+		messages = append(messages, fmt.Sprintf("%s: %s", problem.Field, problem.Message))
+	}
+	return "invalid group: " + strings.Join(messages, "; ")
+}
+
+type groupValidator struct {
+	problems []ValidationProblem
+}
+
+func (v *groupValidator) addf(field, format string, a ...any) {
+	v.problems = append(v.problems, ValidationProblem{Field: field, Message: fmt.Sprintf(format, a...)})
+}
+
+// Validate checks the group against the constraints NSX Manager enforces on Expression and
+// ExtendedExpression, and returns a *ValidationError listing all violations.
+func (g *Group) Validate() error {
+	v := &groupValidator{}
+
+	v.validateExpressions("expression", g.Expression, len(g.ExtendedExpression) > 0)
+	v.validateExtendedExpressions(g.ExtendedExpression)
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+func (v *groupValidator) validateExpressions(field string, expressions []DynamicExpressionWrapper, extended bool) {
+	if len(expressions) == 0 {
+		return
+	}
+
+	if len(expressions)%2 == 0 {
+		v.addf(field, "must have an odd number of expressions, got %d", len(expressions))
+	}
+
+	conditions := 0
+	members := 0
+
+	for i, wrapper := range expressions {
+		elementField := fmt.Sprintf("%s[%d]", field, i)
+		_, isConjunction := wrapper.Expression.(*ExpressionConjunctionOperator)
+
+		switch {
+		case wrapper.Expression == nil:
+			v.addf(elementField, "expression is empty")
+			continue
+		case i%2 == 1 && !isConjunction:
+			v.addf(elementField, "expected a ConjunctionOperator at an odd index, got %s", expressionTypeName(wrapper.Expression))
+		case i%2 == 0 && isConjunction:
+			v.addf(elementField, "expected an expression at an even index, got a ConjunctionOperator")
+		}
+
+		switch expression := wrapper.Expression.(type) {
+		case *ExpressionConjunctionOperator:
+			if expression.ConjunctionOperator == nil || (*expression.ConjunctionOperator != "AND" && *expression.ConjunctionOperator != "OR") {
+				v.addf(elementField+".conjunction_operator", "must be AND or OR")
+			}
+		case *ExpressionCondition:
+			conditions++
+			v.validateCondition(elementField, expression)
+		case *ExpressionNested:
+			conditions++
+			if len(expression.Expressions) == 0 {
+				v.addf(elementField+".expressions", "at least one expression is required")
+			}
+			v.validateExpressions(elementField+".expressions", expression.Expressions, extended)
+		case *ExpressionIPAddress:
+			members += len(expression.IpAddresses)
+			if len(expression.IpAddresses) == 0 {
+				v.addf(elementField+".ip_addresses", "at least one IP address is required")
+			}
+		case *ExpressionMACAddress:
+			members += len(expression.MacAddresses)
+			if len(expression.MacAddresses) == 0 {
+				v.addf(elementField+".mac_addresses", "at least one MAC address is required")
+			}
+		case *ExpressionExternalID:
+			members += len(expression.ExternalIds)
+			if len(expression.ExternalIds) == 0 {
+				v.addf(elementField+".external_ids", "at least one external id is required")
+			}
+			if expression.ExternalIdType == nil {
+				v.addf(elementField+".member_type", "is required")
+			}
+		case *ExpressionPath:
+			members += len(expression.Paths)
+			if len(expression.Paths) == 0 {
+				v.addf(elementField+".paths", "at least one path is required")
+			}
+			if extended {
+				v.addf(elementField, "nesting is not supported when extended_expression is used")
+			}
+		case *ExpressionIdentityGroup:
+			v.addf(elementField, "IdentityGroupExpression is only allowed in extended_expression")
+		}
+	}
+
+	if conditions > MaxGroupConditions {
+		v.addf(field, "has %d Condition and nested expressions, at most %d are allowed", conditions, MaxGroupConditions)
+	}
+	if members > MaxGroupMembers {
+		v.addf(field, "has %d IP addresses, MAC addresses, external ids and paths, at most %d are allowed", members, MaxGroupMembers)
+	}
+}
+
+func (v *groupValidator) validateCondition(field string, condition *ExpressionCondition) {
+	if condition.Key == nil || *condition.Key == "" {
+		v.addf(field+".key", "is required")
+	}
+	if condition.Value == nil {
+		v.addf(field+".value", "is required")
+	}
+	if condition.MemberType == nil || *condition.MemberType == "" {
+		v.addf(field+".member_type", "is required")
+	}
+	if condition.Operator != nil {
+		switch *condition.Operator {
+		case "EQUALS", "NOTEQUALS", "CONTAINS", "STARTSWITH", "ENDSWITH", "IN", "NOTIN", "MATCHES":
+		default:
+			v.addf(field+".operator", "unknown operator %q", *condition.Operator)
+		}
+	}
+	if condition.ScopeOperator != nil && *condition.ScopeOperator != "EQUALS" && *condition.ScopeOperator != "NOTEQUALS" {
+		v.addf(field+".scope_operator", "must be EQUALS or NOTEQUALS")
+	}
+}
+
+func (v *groupValidator) validateExtendedExpressions(expressions []DynamicExpressionWrapper) {
+	if len(expressions) == 0 {
+		return
+	}
+
+	if len(expressions) != 1 {
+		v.addf("extended_expression", "must contain a single IdentityGroupExpression, got %d expressions", len(expressions))
+	}
+
+	for i, wrapper := range expressions {
+		elementField := fmt.Sprintf("extended_expression[%d]", i)
+
+		identityGroup, ok := wrapper.Expression.(*ExpressionIdentityGroup)
+		if !ok {
+			v.addf(elementField, "only IdentityGroupExpression is supported, got %s", expressionTypeName(wrapper.Expression))
+			continue
+		}
+
+		if len(identityGroup.IdentityGroups) == 0 {
+			v.addf(elementField+".identity_groups", "at least one identity group is required")
+		}
+		for j, info := range identityGroup.IdentityGroups {
+			if info.DistinguishedName == "" {
+				v.addf(fmt.Sprintf("%s.identity_groups[%d].distinguished_name", elementField, j), "is required")
+			}
+			if info.DomainBaseDN == "" {
+				v.addf(fmt.Sprintf("%s.identity_groups[%d].domain_base_distinquished_name", elementField, j), "is required")
+			}
+		}
+	}
+}
+
+// expressionTypeName returns the NSX resource type of an expression
+func expressionTypeName(expression DynamicExpression) string {
+	switch expression.(type) {
+	case *ExpressionCondition:
+		return "Condition"
+	case *ExpressionConjunctionOperator:
+		return "ConjunctionOperator"
+	case *ExpressionIPAddress:
+		return "IPAddressExpression"
+	case *ExpressionPath:
+		return "PathExpression"
+	case *ExpressionExternalID:
+		return "ExternalIDExpression"
+	case *ExpressionMACAddress:
+		return "MACAddressExpression"
+	case *ExpressionIdentityGroup:
+		return "IdentityGroupExpression"
+	case *ExpressionNested:
+		return "NestedExpression"
+	case nil:
+		return "nothing"
+	}
+	return fmt.Sprintf("%T", expression)
+}
@@ -0,0 +1,438 @@
+package gonsx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// The hierarchical policy API applies a tree of changes below /infra in a single transaction,
+// either all changes are applied or none. Every object in the tree is wrapped in a Child*
+// object, which marks it for deletion when MarkedForDelete is set.
+
+// HierarchicalChild is a Child* wrapper in a hierarchical API request
+type HierarchicalChild interface {
+	json.Marshaler
+	hierarchicalChild()
+}
+
+// Domain is a policy domain, groups and security policies live in a domain
+type Domain struct {
+	BaseNsxPolicyApiResource
+}
+
+// Infra is the root of a hierarchical API request
+type Infra struct {
+	Children []HierarchicalChild
+}
+
+func (i Infra) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"resource_type": "Infra",
+		"children":      childrenOrEmpty(i.Children),
+	})
+}
+
+// ChildDomain wraps a domain and the changes to its groups and security policies
+type ChildDomain struct {
+	Domain          Domain
+	MarkedForDelete bool
+	Children        []HierarchicalChild
+}
+
+// ChildGroup wraps a group
+type ChildGroup struct {
+	Group           Group
+	MarkedForDelete bool
+}
+
+// ChildService wraps a service
+type ChildService struct {
+	Service         Service
+	MarkedForDelete bool
+}
+
+// ChildSecurityPolicy wraps a security policy and the changes to its rules
+type ChildSecurityPolicy struct {
+	SecurityPolicy  SecurityPolicy
+	MarkedForDelete bool
+	Children        []HierarchicalChild
+}
+
+// ChildRule wraps a rule
+type ChildRule struct {
+	Rule            Rule
+	MarkedForDelete bool
+}
+
+func (ChildDomain) hierarchicalChild()         {}
+func (ChildGroup) hierarchicalChild()          {}
+func (ChildService) hierarchicalChild()        {}
+func (ChildSecurityPolicy) hierarchicalChild() {}
+func (ChildRule) hierarchicalChild()           {}
+
+func (c ChildDomain) MarshalJSON() ([]byte, error) {
+	domain := c.Domain
+	setResourceType(&domain.BaseNsxPolicyApiResource, "Domain")
+	return marshalChild("ChildDomain", "Domain", domain, c.MarkedForDelete, c.Children)
+}
+
+func (c ChildGroup) MarshalJSON() ([]byte, error) {
+	group := c.Group
+	setResourceType(&group.BaseNsxPolicyApiResource, "Group")
+	return marshalChild("ChildGroup", "Group", group, c.MarkedForDelete, nil)
+}
+
+func (c ChildService) MarshalJSON() ([]byte, error) {
+	service := c.Service
+	setResourceType(&service.BaseNsxPolicyApiResource, "Service")
+	return marshalChild("ChildService", "Service", service, c.MarkedForDelete, nil)
+}
+
+func (c ChildSecurityPolicy) MarshalJSON() ([]byte, error) {
+	policy := c.SecurityPolicy
+	setResourceType(&policy.BaseNsxPolicyApiResource, "SecurityPolicy")
+	policy.Rules = append([]Rule(nil), policy.Rules...)
+	for i := range policy.Rules {
+		setResourceType(&policy.Rules[i].BaseNsxPolicyApiResource, "Rule")
+	}
+	return marshalChild("ChildSecurityPolicy", "SecurityPolicy", policy, c.MarkedForDelete, c.Children)
+}
+
+func (c ChildRule) MarshalJSON() ([]byte, error) {
+	rule := c.Rule
+	setResourceType(&rule.BaseNsxPolicyApiResource, "Rule")
+	return marshalChild("ChildRule", "Rule", rule, c.MarkedForDelete, nil)
+}
+
+func setResourceType(resource *BaseNsxPolicyApiResource, resourceType string) {
+	if resource.ResourceType == nil {
+		resource.ResourceType = stringPointer(resourceType)
+	}
+}
+
+func childrenOrEmpty(children []HierarchicalChild) []HierarchicalChild {
+	if children == nil {
+		return []HierarchicalChild{}
+	}
+	return children
+}
+
+// marshalChild encodes a Child* wrapper, the typed children replace the children of the object
+func marshalChild(childType, objectKey string, object any, markedForDelete bool, children []HierarchicalChild) ([]byte, error) {
+	encoded, err := json.Marshal(object)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]any
+	err = json.Unmarshal(encoded, &fields)
+	if err != nil {
+		return nil, err
+	}
+	if len(children) > 0 {
+		fields["children"] = children
+	}
+
+	wrapper := map[string]any{
+		"resource_type": childType,
+		objectKey:       fields,
+	}
+	if markedForDelete {
+		wrapper["marked_for_delete"] = true
+	}
+
+	return json.Marshal(wrapper)
+}
+
+// HierarchicalBuilder assembles changes to services, groups, security policies and rules into
+// a single hierarchical request. Within the request, services and groups are created before
+// the security policies referencing them, and deleted after them.
+//
+//	err := NewHierarchicalBuilder().
+//		PatchGroup(DefaultDomainId, webGroup).
+//		PatchSecurityPolicy(DefaultDomainId, webPolicy).
+//		DeleteGroup(DefaultDomainId, "old-web").
+//		Apply(ctx, &nsxConfig)
+type HierarchicalBuilder struct {
+	services        []HierarchicalChild
+	deletedServices []HierarchicalChild
+	domains         []*hierarchicalDomain
+	err             error
+}
+
+type hierarchicalDomain struct {
+	id              string
+	markedForDelete bool
+	groups          []HierarchicalChild
+	deletedGroups   []HierarchicalChild
+	policies        []*ChildSecurityPolicy
+	patchedPolicies map[string]bool
+}
+
+// NewHierarchicalBuilder creates an empty builder
+func NewHierarchicalBuilder() *HierarchicalBuilder {
+	return &HierarchicalBuilder{}
+}
+
+func (b *HierarchicalBuilder) setErr(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+func (b *HierarchicalBuilder) domain(domainId string) *hierarchicalDomain {
+	for _, domain := range b.domains {
+		if domain.id == domainId {
+			return domain
+		}
+	}
+	domain := &hierarchicalDomain{id: domainId}
+	b.domains = append(b.domains, domain)
+	return domain
+}
+
+// policy returns the ChildSecurityPolicy for a policy id, adding one that only references the
+// policy by id if the policy itself is not changed
+func (d *hierarchicalDomain) policy(policyId string) *ChildSecurityPolicy {
+	for _, policy := range d.policies {
+		if policy.SecurityPolicy.Id != nil && *policy.SecurityPolicy.Id == policyId {
+			return policy
+		}
+	}
+	policy := &ChildSecurityPolicy{}
+	policy.SecurityPolicy.Id = stringPointer(policyId)
+	d.policies = append(d.policies, policy)
+	return policy
+}
+
+// PatchService creates or updates a service
+func (b *HierarchicalBuilder) PatchService(service Service) *HierarchicalBuilder {
+	if service.Id == nil {
+		b.setErr(fmt.Errorf("service id is required to patch a service"))
+		return b
+	}
+	b.services = append(b.services, ChildService{Service: service})
+	return b
+}
+
+// DeleteService deletes a service
+func (b *HierarchicalBuilder) DeleteService(serviceId string) *HierarchicalBuilder {
+	service := Service{}
+	service.Id = stringPointer(serviceId)
+	b.deletedServices = append(b.deletedServices, ChildService{Service: service, MarkedForDelete: true})
+	return b
+}
+
+// DeleteDomain deletes a domain with all its groups and security policies
+func (b *HierarchicalBuilder) DeleteDomain(domainId string) *HierarchicalBuilder {
+	b.domain(domainId).markedForDelete = true
+	return b
+}
+
+// PatchGroup creates or updates a group in a domain
+func (b *HierarchicalBuilder) PatchGroup(domainId string, group Group) *HierarchicalBuilder {
+	if group.Id == nil {
+		b.setErr(fmt.Errorf("group id is required to patch a group"))
+		return b
+	}
+	domain := b.domain(domainId)
+	domain.groups = append(domain.groups, ChildGroup{Group: group})
+	return b
+}
+
+// DeleteGroup deletes a group in a domain
+func (b *HierarchicalBuilder) DeleteGroup(domainId, groupId string) *HierarchicalBuilder {
+	group := Group{}
+	group.Id = stringPointer(groupId)
+	domain := b.domain(domainId)
+	domain.deletedGroups = append(domain.deletedGroups, ChildGroup{Group: group, MarkedForDelete: true})
+	return b
+}
+
+// PatchSecurityPolicy creates or updates a security policy in a domain, together with its
+// embedded Rules. The rules of a policy are either embedded or changed with PatchRule and
+// DeleteRule, a request can't do both for the same policy.
+func (b *HierarchicalBuilder) PatchSecurityPolicy(domainId string, policy SecurityPolicy) *HierarchicalBuilder {
+	if policy.Id == nil {
+		b.setErr(fmt.Errorf("security policy id is required to patch a security policy"))
+		return b
+	}
+	child := b.domain(domainId).policy(*policy.Id)
+	if child.MarkedForDelete {
+		b.setErr(fmt.Errorf("security policy %s is both patched and deleted", *policy.Id))
+		return b
+	}
+	if len(policy.Rules) > 0 && len(child.Children) > 0 {
+		b.setErr(fmt.Errorf("security policy %s has both embedded rules and rule changes", *policy.Id))
+		return b
+	}
+	child.SecurityPolicy = policy
+	domain := b.domain(domainId)
+	if domain.patchedPolicies == nil {
+		domain.patchedPolicies = map[string]bool{}
+	}
+	domain.patchedPolicies[*policy.Id] = true
+	return b
+}
+
+// DeleteSecurityPolicy deletes a security policy in a domain, including its rules
+func (b *HierarchicalBuilder) DeleteSecurityPolicy(domainId, policyId string) *HierarchicalBuilder {
+	domain := b.domain(domainId)
+	child := domain.policy(policyId)
+	if domain.patchedPolicies[policyId] || len(child.Children) > 0 {
+		b.setErr(fmt.Errorf("security policy %s is both changed and deleted", policyId))
+		return b
+	}
+	child.MarkedForDelete = true
+	return b
+}
+
+// PatchRule creates or updates a rule in a security policy
+func (b *HierarchicalBuilder) PatchRule(domainId, policyId string, rule Rule) *HierarchicalBuilder {
+	if rule.Id == nil {
+		b.setErr(fmt.Errorf("rule id is required to patch a rule"))
+		return b
+	}
+	return b.addRule(domainId, policyId, ChildRule{Rule: rule})
+}
+
+// DeleteRule deletes a rule from a security policy
+func (b *HierarchicalBuilder) DeleteRule(domainId, policyId, ruleId string) *HierarchicalBuilder {
+	rule := Rule{}
+	rule.Id = stringPointer(ruleId)
+	return b.addRule(domainId, policyId, ChildRule{Rule: rule, MarkedForDelete: true})
+}
+
+func (b *HierarchicalBuilder) addRule(domainId, policyId string, rule ChildRule) *HierarchicalBuilder {
+	child := b.domain(domainId).policy(policyId)
+	if child.MarkedForDelete {
+		b.setErr(fmt.Errorf("security policy %s is deleted, its rules can't be changed", policyId))
+		return b
+	}
+	if len(child.SecurityPolicy.Rules) > 0 {
+		b.setErr(fmt.Errorf("security policy %s has both embedded rules and rule changes", policyId))
+		return b
+	}
+	child.Children = append(child.Children, rule)
+	return b
+}
+
+// Build returns the hierarchical request
+func (b *HierarchicalBuilder) Build() (*Infra, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	infra := &Infra{}
+	infra.Children = append(infra.Children, b.services...)
+
+	for _, domain := range b.domains {
+		child := ChildDomain{MarkedForDelete: domain.markedForDelete}
+		child.Domain.Id = stringPointer(domain.id)
+
+		child.Children = append(child.Children, domain.groups...)
+		for _, policy := range domain.policies {
+			child.Children = append(child.Children, *policy)
+		}
+		child.Children = append(child.Children, domain.deletedGroups...)
+
+		infra.Children = append(infra.Children, child)
+	}
+
+	infra.Children = append(infra.Children, b.deletedServices...)
+
+	if len(infra.Children) == 0 {
+		return nil, fmt.Errorf("hierarchical request is empty")
+	}
+
+	return infra, nil
+}
+
+// Apply builds the request and submits it with PATCH /infra, NSX applies all changes or none
+func (b *HierarchicalBuilder) Apply(ctx context.Context, nsxConfig *NSXClient) error {
+	infra, err := b.Build()
+	if err != nil {
+		return err
+	}
+	return PatchInfra(ctx, nsxConfig, infra)
+}
+
+// PatchInfra submits a hierarchical request
+func PatchInfra(ctx context.Context, nsxConfig *NSXClient, infra *Infra) error {
+	return patchResource(ctx, nsxConfig, "/infra", infra, nil)
+}
+
+// ApplyHierarchical applies the plan as a single hierarchical request, so either the whole
+// plan is applied or nothing is. The rules of a security policy are sent as rule changes,
+// including the deletion of live rules that are not in the desired policy.
+func (p *Plan) ApplyHierarchical(ctx context.Context, nsxConfig *NSXClient) error {
+	if !p.HasChanges() {
+		return nil
+	}
+
+	b := NewHierarchicalBuilder()
+
+	for _, change := range p.Changes {
+		domainId := domainFromPath(change.Path)
+		id := path.Base(change.Path)
+
+		switch change.Action {
+		case ChangeNoop:
+		case ChangeDelete:
+			switch change.Kind {
+			case "Group":
+				b.DeleteGroup(domainId, id)
+			case "Service":
+				b.DeleteService(id)
+			case "SecurityPolicy":
+				b.DeleteSecurityPolicy(domainId, id)
+			}
+		default:
+			// desired objects may only have a path, the builder needs their id
+			switch desired := change.Desired.(type) {
+			case *Group:
+				group := *desired
+				if group.Id == nil {
+					group.Id = &id
+				}
+				b.PatchGroup(domainId, group)
+			case *Service:
+				service := *desired
+				if service.Id == nil {
+					service.Id = &id
+				}
+				b.PatchService(service)
+			case *SecurityPolicy:
+				policy := *desired
+				if policy.Id == nil {
+					policy.Id = &id
+				}
+				policy.Rules = nil
+				b.PatchSecurityPolicy(domainId, policy)
+				for _, rule := range desired.Rules {
+					b.PatchRule(domainId, id, rule)
+				}
+				live, _ := change.Live.(*SecurityPolicy)
+				for _, ruleId := range removedRules(desired, live) {
+					b.DeleteRule(domainId, id, ruleId)
+				}
+			}
+		}
+	}
+
+	return b.Apply(ctx, nsxConfig)
+}
+
+// domainFromPath returns the domain id of a path like /infra/domains/<domain>/groups/<id>
+func domainFromPath(path string) string {
+	parts := strings.Split(path, "/")
+	for i := 0; i+1 < len(parts); i++ {
+		if parts[i] == "domains" {
+			return parts[i+1]
+		}
+	}
+	return DefaultDomainId
+}
@@ -0,0 +1,190 @@
+package gonsx
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// checkGolden compares json with the golden file testdata/name, ignoring formatting
+func checkGolden(t *testing.T, name string, encoded []byte) {
+	t.Helper()
+	golden := filepath.Join("testdata", name)
+
+	if *updateGolden {
+		var indented any
+		if err := json.Unmarshal(encoded, &indented); err != nil {
+			t.Fatal(err)
+		}
+		data, err := json.MarshalIndent(indented, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(golden, append(data, '\n'), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want, got any
+	if err := json.Unmarshal(data, &want); err != nil {
+		t.Fatalf("error decoding %s: %v", golden, err)
+	}
+	if err := json.Unmarshal(encoded, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("json differs from %s\nwant: %s\ngot:  %s", golden, data, encoded)
+	}
+}
+
+func testService(id string) Service {
+	service := Service{}
+	service.Id = stringPointer(id)
+	service.DisplayName = stringPointer(id)
+	return service
+}
+
+func TestHierarchicalBuilder(t *testing.T) {
+	web := testGroup("web", "web", "/infra/domains/default/groups/app")
+	rule := testRule("allow-web", "ALLOW", 10)
+	rule.DestinationGroups = []string{"/infra/domains/default/groups/web"}
+
+	tests := []struct {
+		name    string
+		builder *HierarchicalBuilder
+	}{
+		{
+			name: "patch",
+			builder: NewHierarchicalBuilder().
+				PatchService(testService("https")).
+				PatchGroup(DefaultDomainId, web).
+				PatchSecurityPolicy(DefaultDomainId, testPolicy("web", "Application", 1, rule)),
+		},
+		{
+			name: "delete",
+			builder: NewHierarchicalBuilder().
+				DeleteSecurityPolicy(DefaultDomainId, "web").
+				DeleteGroup(DefaultDomainId, "web").
+				DeleteService("https"),
+		},
+		{
+			name: "rule_delete",
+			builder: NewHierarchicalBuilder().
+				PatchRule(DefaultDomainId, "web", rule).
+				DeleteRule(DefaultDomainId, "web", "old"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			infra, err := test.builder.Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			encoded, err := json.Marshal(infra)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, filepath.Join("hierarchical", test.name+".json"), encoded)
+		})
+	}
+}
+
+func TestHierarchicalBuilderErrors(t *testing.T) {
+	rule := testRule("allow-web", "ALLOW", 10)
+	policy := testPolicy("web", "Application", 1, rule)
+
+	tests := []struct {
+		name    string
+		builder *HierarchicalBuilder
+	}{
+		{
+			name:    "patch and delete a policy",
+			builder: NewHierarchicalBuilder().PatchSecurityPolicy(DefaultDomainId, policy).DeleteSecurityPolicy(DefaultDomainId, "web"),
+		},
+		{
+			name:    "delete and patch a policy",
+			builder: NewHierarchicalBuilder().DeleteSecurityPolicy(DefaultDomainId, "web").PatchSecurityPolicy(DefaultDomainId, policy),
+		},
+		{
+			name:    "rule change on a deleted policy",
+			builder: NewHierarchicalBuilder().DeleteSecurityPolicy(DefaultDomainId, "web").DeleteRule(DefaultDomainId, "web", "old"),
+		},
+		{
+			name:    "embedded rules and a rule delete",
+			builder: NewHierarchicalBuilder().PatchSecurityPolicy(DefaultDomainId, policy).DeleteRule(DefaultDomainId, "web", "old"),
+		},
+		{
+			name:    "rule delete and embedded rules",
+			builder: NewHierarchicalBuilder().DeleteRule(DefaultDomainId, "web", "old").PatchSecurityPolicy(DefaultDomainId, policy),
+		},
+		{
+			name:    "group without id",
+			builder: NewHierarchicalBuilder().PatchGroup(DefaultDomainId, Group{}),
+		},
+		{
+			name:    "empty request",
+			builder: NewHierarchicalBuilder(),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := test.builder.Build(); err == nil {
+				t.Error("Build succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestPlanApplyHierarchical(t *testing.T) {
+	groupPath := GroupPath(DefaultDomainId, "app-web")
+	policyPath := SecurityPolicyPath(DefaultDomainId, "app-policy")
+
+	// desired objects with a path only, as ComputePlan accepts them
+	group := Group{}
+	group.Path = stringPointer(groupPath)
+	group.DisplayName = stringPointer("web")
+
+	policy := SecurityPolicy{Rules: []Rule{testRule("keep", "DROP", 1)}}
+	policy.Path = stringPointer(policyPath)
+
+	oldPolicy := testPolicy("app-policy", "Application", 1, testRule("keep", "ALLOW", 1), testRule("remove", "DROP", 2))
+
+	plan, err := ComputePlan(
+		&ObjectSet{Groups: []Group{group}, Policies: []SecurityPolicy{policy}},
+		&ObjectSet{Policies: []SecurityPolicy{livePolicy(oldPolicy, policyPath)}},
+		PlanOptions{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var body []byte
+	nsxConfig := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PATCH" || r.URL.Path != PolicyApiPrefix+"/infra" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		body, _ = io.ReadAll(r.Body)
+	}))
+
+	err = plan.ApplyHierarchical(context.Background(), &nsxConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkGolden(t, filepath.Join("hierarchical", "plan.json"), body)
+}
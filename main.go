@@ -1,9 +1,17 @@
+// Package gonsx is a client for the NSX Policy API.
+//
+// Every call that talks to NSX takes a context.Context. The calls that predate context
+// support, NSXClient.NewRequest, SearchForPageOfType, SearchForAllOfType and the Group
+// member lookups, keep their signature for backward compatibility and have a WithContext
+// variant. Calls added since then only exist in the context form.
 package gonsx
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"strconv"
 	"time"
@@ -15,50 +23,144 @@ type NSXClient struct {
 	Password string
 	Hostname string
 	Client   *http.Client
+	// Authentication mode, basic auth with Username and Password is used when nil
+	Auth Authenticator
+	// Retry behaviour of Do, DefaultRetryPolicy is used when nil
+	RetryPolicy *RetryPolicy
+	// Maximum number of search pages fetched in parallel, DefaultMaxConcurrentPages is used when 0
+	MaxConcurrentPages int
+	// Limits the rate of all requests sent by Do, including retries. Share it between clients
+	// to limit the total rate against a manager. No limit is applied when nil.
+	RateLimiter *RateLimiter
+	// Logger for retries and other noteworthy events, nothing is logged when nil
+	Logger *log.Logger
 }
 
+func (nsxConfig *NSXClient) logf(format string, v ...any) {
+	if nsxConfig.Logger != nil {
+		nsxConfig.Logger.Printf(format, v...)
+	}
+}
+
+// NewRequest creates an authenticated request, kept for backward compatibility, use NewRequestWithContext
 func (nsxConfig *NSXClient) NewRequest(method, url string, body io.Reader) (*http.Request, error) {
-	req, err := http.NewRequest(method, url, body)
+	return nsxConfig.NewRequestWithContext(context.Background(), method, url, body)
+}
+
+// NewRequestWithContext is like NewRequest, but the returned request is bound to ctx
+func (nsxConfig *NSXClient) NewRequestWithContext(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return req, err
 	}
 
-	// add basic auth to request
-	req.SetBasicAuth(nsxConfig.Username, nsxConfig.Password)
+	// add credentials to request
+	err = nsxConfig.authenticator().Authenticate(req, nsxConfig)
 
 	return req, err
 }
 
+// Close releases the resources held by the client, e.g. destroys the NSX session of a SessionAuth
+func (nsxConfig *NSXClient) Close(ctx context.Context) error {
+	if closer, ok := nsxConfig.Auth.(authCloser); ok {
+		return closer.Close(ctx, nsxConfig)
+	}
+	return nil
+}
+
+// Do sends the request, retrying throttled and failed requests according to the RetryPolicy.
+// The backoff between retries is aborted as soon as the request context is done. Any non 2xx
+// response is returned as an *APIError, which can be matched with errors.Is against ErrNotFound,
+// ErrConflict etc.
 func (nsxConfig *NSXClient) Do(req *http.Request) (*http.Response, error) {
-	// send http request
-	resp, err := nsxConfig.Client.Do(req)
+	policy := nsxConfig.retryPolicy()
+
+	// buffer the body, a drained body can't be sent again on a retry
+	err := makeReplayable(req)
 	if err != nil {
 		return nil, err
 	}
 
-	backoffRetries := 1
+	var resp *http.Response
+	reauthenticated := false
+
+	for attempt := 1; ; attempt++ {
+		if nsxConfig.RateLimiter != nil {
+			err = nsxConfig.RateLimiter.Wait(req.Context())
+			if err != nil {
+				return nil, err
+			}
+		}
 
-	// check for throttling
-	for backoffRetries < 10 && (resp.StatusCode == 429 || resp.StatusCode == 503) {
-		fmt.Println("Throttling detected, sleeping a bit and retrying")
-		// make sure to close the trashed response
-		resp.Body.Close()
-		time.Sleep(time.Duration(backoffRetries) * time.Second)
 		resp, err = nsxConfig.Client.Do(req)
+
+		// the credentials expired, log in again once and resend
+		reauthenticator, canReauthenticate := nsxConfig.Auth.(Reauthenticator)
+		if err == nil && canReauthenticate && !reauthenticated && isAuthExpired(resp) {
+			reauthenticated = true
+			resp.Body.Close()
+
+			err = reauthenticator.Reauthenticate(req, nsxConfig)
+			if err != nil {
+				return nil, err
+			}
+			err = rewindBody(req)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if attempt >= policy.MaxAttempts || req.Context().Err() != nil || !policy.shouldRetry(resp, err) {
+			break
+		}
+
+		delay := policy.delay(attempt, resp)
+		if err != nil {
+			nsxConfig.logf("%s %s failed with %v, retrying in %s", req.Method, req.URL.Path, err, delay)
+		} else {
+			nsxConfig.logf("%s %s returned HTTP %d, retrying in %s", req.Method, req.URL.Path, resp.StatusCode, delay)
+			// make sure to close the trashed response
+			resp.Body.Close()
+		}
+
+		err = sleepContext(req.Context(), delay)
 		if err != nil {
 			return nil, err
 		}
-		backoffRetries++
+
+		err = rewindBody(req)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err != nil {
+		return nil, err
 	}
 
-	// handle unauthorized error
-	if resp.StatusCode == 403 {
-		return nil, fmt.Errorf("HTTP 403: Unauthorized, please verify credentials, and vIDM status")
+	// turn any non 2xx response into an APIError, so callers never decode an error body as a result
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		return nil, newAPIError(resp)
 	}
 
 	return resp, err
 }
 
+// sleepContext sleeps for d, or returns early with the context error if ctx is done first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
 type searchCursor int
 
 func (s *searchCursor) UnmarshalJSON(data []byte) error {
@@ -94,16 +196,42 @@ type NsxBulkResponse[t NsxApiResource] struct {
 }
 
 const (
-	SearchPageSize = 1000
-	SearchEndpoint = "/policy/api/v1/search"
+	SearchPageSize            = 1000
+	SearchEndpoint            = "/policy/api/v1/search"
+	DefaultMaxConcurrentPages = 8
 )
 
-// search for all of a single type, and cursor through the results
+func (nsxConfig *NSXClient) maxConcurrentPages() int {
+	if nsxConfig.MaxConcurrentPages > 0 {
+		return nsxConfig.MaxConcurrentPages
+	}
+	return DefaultMaxConcurrentPages
+}
+
+// search for all of a single type, and cursor through the results. Kept for backward
+// compatibility, use SearchForPageOfTypeWithContext.
 func SearchForPageOfType[t NsxApiResource](nsxConfig NSXClient, resourceType string, cursor int) (NsxBulkResponse[t], error) {
-	initialRequest := fmt.Sprintf("https://%s%s?query=resource_type:%s&page_size=%d&cursor=%d", nsxConfig.Hostname, SearchEndpoint, resourceType, SearchPageSize, cursor)
+	return SearchForPageOfTypeWithContext[t](context.Background(), nsxConfig, resourceType, cursor)
+}
+
+// SearchForPageOfTypeWithContext is like SearchForPageOfType, but the request is bound to ctx
+func SearchForPageOfTypeWithContext[t NsxApiResource](ctx context.Context, nsxConfig NSXClient, resourceType string, cursor int) (NsxBulkResponse[t], error) {
+	searchResponse, err := SearchPage[t](ctx, nsxConfig, NewSearchQuery(ResourceTypeIs(resourceType)), cursor)
+	if err != nil {
+		return NsxBulkResponse[t]{}, err
+	}
+
+	if len(searchResponse.Results) == 0 {
+		return NsxBulkResponse[t]{}, fmt.Errorf("no results found for type \"%s\" at cursor location %d", resourceType, cursor)
+	}
 
+	return searchResponse, nil
+}
+
+// SearchPage fetches a single page of results for a search query, a page without results is not an error
+func SearchPage[t NsxApiResource](ctx context.Context, nsxConfig NSXClient, query *SearchQuery, cursor int) (NsxBulkResponse[t], error) {
 	// create http request
-	req, err := nsxConfig.NewRequest("GET", initialRequest, nil)
+	req, err := nsxConfig.NewRequestWithContext(ctx, "GET", query.URL(nsxConfig.Hostname, cursor), nil)
 	if err != nil {
 		return NsxBulkResponse[t]{}, err
 	}
@@ -124,62 +252,114 @@ func SearchForPageOfType[t NsxApiResource](nsxConfig NSXClient, resourceType str
 
 	}
 
-	if len(searchResponse.Results) == 0 {
-		return NsxBulkResponse[t]{}, fmt.Errorf("no results found for type \"%s\" at cursor location %d", resourceType, cursor)
-	}
-
 	return searchResponse, nil
 }
 
-// search for a single type, and cursor through the pages to get all results
+// search for a single type, and cursor through the pages to get all results. Kept for backward
+// compatibility, use SearchForAllOfTypeWithContext.
 func SearchForAllOfType[t NsxApiResource](nsxConfig NSXClient, resourceType string) ([]t, error) {
-	// get the first page of results
-	bulkResponse, err := SearchForPageOfType[t](nsxConfig, resourceType, 0)
+	return SearchForAllOfTypeWithContext[t](context.Background(), nsxConfig, resourceType)
+}
+
+// SearchForAllOfTypeWithContext is like SearchForAllOfType, but all page fetches are bound
+// to ctx. Outstanding fetches are cancelled as soon as ctx is done or one of them fails.
+func SearchForAllOfTypeWithContext[t NsxApiResource](ctx context.Context, nsxConfig NSXClient, resourceType string) ([]t, error) {
+	results, err := SearchAll[t](ctx, nsxConfig, NewSearchQuery(ResourceTypeIs(resourceType)))
 	if err != nil {
 		return nil, err
 	}
 
-	if bulkResponse.Cursor == nil {
-		return nil, fmt.Errorf("no cursor found in search response, something is wrong")
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no results found for type \"%s\" at cursor location %d", resourceType, 0)
 	}
 
-	if bulkResponse.ResultCount == int(*bulkResponse.Cursor) {
+	return results, nil
+}
+
+// SearchAll fetches all results for a search query. After the first page, the remaining pages
+// are fetched in parallel by up to MaxConcurrentPages workers, and cancelled as soon as ctx is
+// done or one of them fails. Results are returned in page order.
+func SearchAll[t NsxApiResource](ctx context.Context, nsxConfig NSXClient, query *SearchQuery) ([]t, error) {
+	// get the first page of results
+	bulkResponse, err := SearchPage[t](ctx, nsxConfig, query, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if bulkResponse.Cursor == nil || len(bulkResponse.Results) >= bulkResponse.ResultCount {
 		return bulkResponse.Results, nil
 	}
 
-	results := bulkResponse.Results
 	var cursorList []int
 	startingCursor := int(*bulkResponse.Cursor)
 
-	for i := startingCursor; i < bulkResponse.ResultCount; i += SearchPageSize {
+	for i := startingCursor; i < bulkResponse.ResultCount; i += query.pageSize() {
 		cursorList = append(cursorList, i)
 	}
 
+	pages, err := fetchPagesInOrder[t](ctx, nsxConfig, query, cursorList)
+	if err != nil {
+		return nil, err
+	}
+
+	results := bulkResponse.Results
+	for _, page := range pages {
+		results = append(results, page.Results...)
+	}
+
+	return results, nil
+}
+
+// fetchPagesInOrder fetches the pages at cursorList in parallel, and returns them in cursor order
+func fetchPagesInOrder[t NsxApiResource](ctx context.Context, nsxConfig NSXClient, query *SearchQuery, cursorList []int) ([]NsxBulkResponse[t], error) {
 	type threadResult struct {
-		results []t
-		err     error
+		index int
+		page  NsxBulkResponse[t]
+		err   error
 	}
 
+	// cancel the remaining page fetches when we return early
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	resultChannel := make(chan threadResult, len(cursorList))
+	indexChannel := make(chan int, len(cursorList))
 
-	for _, cursor := range cursorList {
-		go func(cursor int) {
-			bulkResponse, err := SearchForPageOfType[t](nsxConfig, resourceType, cursor)
-			if err != nil {
-				resultChannel <- threadResult{nil, err}
+	for i := range cursorList {
+		indexChannel <- i
+	}
+	close(indexChannel)
+
+	workers := nsxConfig.maxConcurrentPages()
+	if workers > len(cursorList) {
+		workers = len(cursorList)
+	}
+
+	for w := 0; w < workers; w++ {
+		go func() {
+			for index := range indexChannel {
+				page, err := SearchPage[t](ctx, nsxConfig, query, cursorList[index])
+				resultChannel <- threadResult{index, page, err}
 			}
-			resultChannel <- threadResult{bulkResponse.Results, nil}
-		}(cursor)
+		}()
 	}
 
+	pages := make([]NsxBulkResponse[t], len(cursorList))
+
 	for i := 0; i < len(cursorList); i++ {
-		threadResult := <-resultChannel
+		var threadResult threadResult
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case threadResult = <-resultChannel:
+		}
+
 		if threadResult.err != nil {
 			return nil, threadResult.err
 		}
 
-		results = append(results, threadResult.results...)
+		pages[threadResult.index] = threadResult.page
 	}
 
-	return results, nil
+	return pages, nil
 }
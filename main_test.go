@@ -0,0 +1,22 @@
+package gonsx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestClient returns a client sending its requests to handler
+func newTestClient(t *testing.T, handler http.Handler) NSXClient {
+	server := httptest.NewTLSServer(handler)
+	t.Cleanup(server.Close)
+
+	return NSXClient{
+		Username:    "admin",
+		Password:    "secret",
+		Hostname:    strings.TrimPrefix(server.URL, "https://"),
+		Client:      server.Client(),
+		RetryPolicy: &RetryPolicy{MaxAttempts: 1},
+	}
+}
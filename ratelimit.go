@@ -0,0 +1,70 @@
+package gonsx
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket limiting the request rate against NSX Manager. A single
+// RateLimiter can be shared by several NSXClients talking to the same manager.
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter allows requestsPerSecond on average, with bursts of up to burst requests.
+// requestsPerSecond must be positive.
+func NewRateLimiter(requestsPerSecond float64, burst int) (*RateLimiter, error) {
+	if !(requestsPerSecond > 0) {
+		return nil, fmt.Errorf("requests per second must be positive, got %v", requestsPerSecond)
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:   requestsPerSecond,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}, nil
+}
+
+// Wait blocks until a request may be sent, or returns the context error if ctx is done first
+func (r *RateLimiter) Wait(ctx context.Context) error {
+	wait := r.reserve(time.Now())
+	if wait == 0 {
+		return nil
+	}
+
+	err := sleepContext(ctx, wait)
+	if err != nil {
+		// hand the token back, we won't use it
+		r.mu.Lock()
+		r.tokens++
+		r.mu.Unlock()
+	}
+	return err
+}
+
+// reserve takes a token at now and returns how long to wait until it would have been available
+func (r *RateLimiter) reserve(now time.Time) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.tokens += now.Sub(r.last).Seconds() * r.rate
+	if r.tokens > r.burst {
+		r.tokens = r.burst
+	}
+	r.last = now
+
+	r.tokens--
+	if r.tokens < 0 {
+		return time.Duration(-r.tokens / r.rate * float64(time.Second))
+	}
+	return 0
+}
@@ -0,0 +1,48 @@
+package gonsx
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestNewRateLimiterRejectsNonPositiveRates(t *testing.T) {
+	for _, rate := range []float64{0, -1, math.NaN()} {
+		if _, err := NewRateLimiter(rate, 1); err == nil {
+			t.Errorf("NewRateLimiter(%v) succeeded, want an error", rate)
+		}
+	}
+}
+
+func TestRateLimiterReserve(t *testing.T) {
+	limiter, err := NewRateLimiter(10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := limiter.last
+
+	steps := []struct {
+		after time.Duration
+		wait  time.Duration
+	}{
+		// the burst is free
+		{0, 0},
+		{0, 0},
+		// then every request waits for its token, 100ms apart
+		{0, 100 * time.Millisecond},
+		{0, 200 * time.Millisecond},
+		// the waits above are paid back after 300ms
+		{300 * time.Millisecond, 0},
+		// the bucket refills up to the burst only
+		{10 * time.Second, 0},
+		{10 * time.Second, 0},
+		{10 * time.Second, 100 * time.Millisecond},
+	}
+
+	for i, step := range steps {
+		wait := limiter.reserve(start.Add(step.after))
+		if diff := wait - step.wait; diff < -time.Microsecond || diff > time.Microsecond {
+			t.Errorf("request %d at %v waits %v, want %v", i, step.after, wait, step.wait)
+		}
+	}
+}
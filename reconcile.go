@@ -0,0 +1,653 @@
+package gonsx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ServerManagedFields are the json fields NSX populates itself. They are ignored when comparing
+// desired and live objects, and stripped from exported objects.
+var ServerManagedFields = []string{
+	"_create_time",
+	"_create_user",
+	"_last_modified_time",
+	"_last_modified_user",
+	"_links",
+	"_meta",
+	"_protection",
+	"_revision",
+	"_schema",
+	"_self",
+	"_system_owned",
+	"default_rule_id",
+	"internal_sequence_number",
+	"lock_modified_by",
+	"lock_modified_time",
+	"marked_for_delete",
+	"overridden",
+	"parent_path",
+	"path",
+	"realization_id",
+	"relative_path",
+	"rule_count",
+	"rule_id",
+	"state",
+	"status",
+	"unique_id",
+}
+
+// ObjectSet is a set of groups, services and security policies, either desired or live
+type ObjectSet struct {
+	Groups   []Group
+	Services []Service
+	// Security policies including their Rules
+	Policies []SecurityPolicy
+}
+
+// FetchObjectSet fetches all groups, services and security policies with their rules from NSX
+func FetchObjectSet(ctx context.Context, nsxConfig NSXClient) (*ObjectSet, error) {
+	live := &ObjectSet{}
+	var err error
+
+	live.Groups, err = SearchAll[Group](ctx, nsxConfig, NewSearchQuery(ResourceTypeIs("Group"), MarkedForDelete(false)))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching groups: %w", err)
+	}
+
+	live.Services, err = SearchAll[Service](ctx, nsxConfig, NewSearchQuery(ResourceTypeIs("Service"), MarkedForDelete(false)))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching services: %w", err)
+	}
+
+	live.Policies, err = SearchAll[SecurityPolicy](ctx, nsxConfig, NewSearchQuery(ResourceTypeIs("SecurityPolicy"), MarkedForDelete(false)))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching security policies: %w", err)
+	}
+
+	rules, err := SearchAll[Rule](ctx, nsxConfig, NewSearchQuery(ResourceTypeIs("Rule"), MarkedForDelete(false)))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching rules: %w", err)
+	}
+
+	// the search API returns rules separately, put them back into their policy
+	policyIndex := map[string]int{}
+	for i, policy := range live.Policies {
+		if policy.Path != nil {
+			policyIndex[*policy.Path] = i
+		}
+	}
+	for _, rule := range rules {
+		if rule.ParentPath == nil {
+			continue
+		}
+		if i, ok := policyIndex[*rule.ParentPath]; ok {
+			live.Policies[i].Rules = append(live.Policies[i].Rules, rule)
+		}
+	}
+
+	return live, nil
+}
+
+// ChangeAction is what a PlannedChange does to an object
+type ChangeAction string
+
+const (
+	ChangeCreate ChangeAction = "create"
+	ChangeUpdate ChangeAction = "update"
+	ChangeDelete ChangeAction = "delete"
+	ChangeNoop   ChangeAction = "no-op"
+)
+
+// FieldChange is a single changed field, Field is a json path like rules[allow-web].action
+type FieldChange struct {
+	Field string
+	Old   any
+	New   any
+}
+
+func (c FieldChange) String() string {
+	return fmt.Sprintf("%s: %s -> %s", c.Field, formatDiffValue(c.Old), formatDiffValue(c.New))
+}
+
+func formatDiffValue(value any) string {
+	if value == nil {
+		return "<unset>"
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(encoded)
+}
+
+// PlannedChange is a change to a single object
+type PlannedChange struct {
+	Action ChangeAction
+	// Group, Service or SecurityPolicy
+	Kind string
+	Path string
+	// Desired object, nil for deletes
+	Desired any
+	// Live object, nil for creates
+	Live    any
+	Changes []FieldChange
+}
+
+func (c PlannedChange) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s %s %s", c.Action, c.Kind, c.Path)
+	for _, change := range c.Changes {
+		fmt.Fprintf(&sb, "\n    %s", change)
+	}
+	return sb.String()
+}
+
+// Plan is the list of changes to reach the desired state, in execution order
+type Plan struct {
+	Changes []PlannedChange
+}
+
+// HasChanges reports whether applying the plan would change anything
+func (p *Plan) HasChanges() bool {
+	for _, change := range p.Changes {
+		if change.Action != ChangeNoop {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Plan) String() string {
+	var sb strings.Builder
+	for _, change := range p.Changes {
+		if change.Action == ChangeNoop {
+			continue
+		}
+		sb.WriteString(change.String())
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// PlanOptions controls which live objects a plan may delete
+type PlanOptions struct {
+	// Delete live objects that are not in the desired state. Only objects accepted by
+	// Managed are deleted, as NSX has plenty of system groups, services and policies.
+	Prune bool
+	// Decides whether a live object is managed by the desired state, e.g. by a tag or a path prefix.
+	// Required when Prune is set.
+	Managed func(resource *BaseNsxPolicyApiResource) bool
+}
+
+// reconcileObject is a desired or live object with the metadata needed to plan and apply it
+type reconcileObject struct {
+	kind     string
+	path     string
+	resource *BaseNsxPolicyApiResource
+	object   any
+	// paths of other objects this object references
+	references []string
+}
+
+func groupObject(group *Group) reconcileObject {
+	object := reconcileObject{kind: "Group", resource: &group.BaseNsxPolicyApiResource, object: group}
+	object.path = objectPath(object.resource, func(id string) string { return GroupPath(DefaultDomainId, id) })
+	object.references = expressionReferences(group.Expression)
+	return object
+}
+
+func serviceObject(service *Service) reconcileObject {
+	object := reconcileObject{kind: "Service", resource: &service.BaseNsxPolicyApiResource, object: service}
+	object.path = objectPath(object.resource, ServicePath)
+	for _, wrapper := range service.ServiceEntries {
+		if nested, ok := wrapper.ServiceEntry.(*NestedServiceEntry); ok {
+			object.references = append(object.references, nested.NestedServicePath)
+		}
+	}
+	return object
+}
+
+func policyObject(policy *SecurityPolicy) reconcileObject {
+	object := reconcileObject{kind: "SecurityPolicy", resource: &policy.BaseNsxPolicyApiResource, object: policy}
+	object.path = objectPath(object.resource, func(id string) string { return SecurityPolicyPath(DefaultDomainId, id) })
+	object.references = append(object.references, policy.Scope...)
+	for _, rule := range policy.Rules {
+		object.references = append(object.references, rule.SourceGroups...)
+		object.references = append(object.references, rule.DestinationGroups...)
+		object.references = append(object.references, rule.Services...)
+		object.references = append(object.references, rule.Scope...)
+	}
+	return object
+}
+
+// objectPath returns the path of an object, derived from its id in the default domain if it has none
+func objectPath(resource *BaseNsxPolicyApiResource, pathForId func(id string) string) string {
+	if resource.Path != nil {
+		return *resource.Path
+	}
+	if resource.Id != nil {
+		return pathForId(*resource.Id)
+	}
+	return ""
+}
+
+func expressionReferences(expressions []DynamicExpressionWrapper) []string {
+	var references []string
+	for _, wrapper := range expressions {
+		switch expression := wrapper.Expression.(type) {
+		case *ExpressionPath:
+			references = append(references, expression.Paths...)
+		case *ExpressionNested:
+			references = append(references, expressionReferences(expression.Expressions)...)
+		}
+	}
+	return references
+}
+
+func (set *ObjectSet) objects() []reconcileObject {
+	var objects []reconcileObject
+	for i := range set.Groups {
+		objects = append(objects, groupObject(&set.Groups[i]))
+	}
+	for i := range set.Services {
+		objects = append(objects, serviceObject(&set.Services[i]))
+	}
+	for i := range set.Policies {
+		objects = append(objects, policyObject(&set.Policies[i]))
+	}
+	return objects
+}
+
+// ComputePlan compares the desired state with the live state and returns the changes to make,
+// ordered so that referenced groups and services exist before the objects using them, and
+// policies are deleted before the groups and services they reference.
+func ComputePlan(desired, live *ObjectSet, opts PlanOptions) (*Plan, error) {
+	if opts.Prune && opts.Managed == nil {
+		return nil, fmt.Errorf("pruning requires a Managed function selecting the objects that may be deleted")
+	}
+
+	liveObjects := map[string]reconcileObject{}
+	for _, object := range live.objects() {
+		liveObjects[object.path] = object
+	}
+
+	var upserts []PlannedChange
+	desiredPaths := map[string]bool{}
+	desiredObjects := map[string]reconcileObject{}
+
+	for _, object := range desired.objects() {
+		if object.path == "" {
+			return nil, fmt.Errorf("desired %s has neither a path nor an id", object.kind)
+		}
+		if desiredPaths[object.path] {
+			return nil, fmt.Errorf("desired %s %s is listed twice", object.kind, object.path)
+		}
+		desiredPaths[object.path] = true
+		desiredObjects[object.path] = object
+
+		liveObject, exists := liveObjects[object.path]
+		if !exists {
+			upserts = append(upserts, PlannedChange{Action: ChangeCreate, Kind: object.kind, Path: object.path, Desired: object.object})
+			continue
+		}
+
+		changes, err := diffObjects(object.object, liveObject.object)
+		if err != nil {
+			return nil, fmt.Errorf("error comparing %s %s: %w", object.kind, object.path, err)
+		}
+
+		action := ChangeNoop
+		if len(changes) > 0 {
+			action = ChangeUpdate
+		}
+		upserts = append(upserts, PlannedChange{Action: action, Kind: object.kind, Path: object.path, Desired: object.object, Live: liveObject.object, Changes: changes})
+	}
+
+	var deletes []PlannedChange
+	if opts.Prune {
+		for _, object := range live.objects() {
+			if desiredPaths[object.path] || !opts.Managed(object.resource) {
+				continue
+			}
+			deletes = append(deletes, PlannedChange{Action: ChangeDelete, Kind: object.kind, Path: object.path, Live: object.object})
+		}
+	}
+
+	upserts = orderByReferences(upserts, func(change PlannedChange) []string {
+		return desiredObjects[change.Path].references
+	})
+	deletes = orderByReferences(deletes, func(change PlannedChange) []string {
+		return liveObjects[change.Path].references
+	})
+
+	// delete in reverse dependency order, objects before the objects they reference
+	for i, j := 0, len(deletes)-1; i < j; i, j = i+1, j-1 {
+		deletes[i], deletes[j] = deletes[j], deletes[i]
+	}
+
+	return &Plan{Changes: append(upserts, deletes...)}, nil
+}
+
+// kindOrder makes groups and services come before the policies referencing them
+var kindOrder = map[string]int{"Service": 0, "Group": 1, "SecurityPolicy": 2}
+
+// orderByReferences orders changes so referenced objects come before the objects referencing them
+func orderByReferences(changes []PlannedChange, references func(PlannedChange) []string) []PlannedChange {
+	sort.SliceStable(changes, func(i, j int) bool {
+		if kindOrder[changes[i].Kind] != kindOrder[changes[j].Kind] {
+			return kindOrder[changes[i].Kind] < kindOrder[changes[j].Kind]
+		}
+		return changes[i].Path < changes[j].Path
+	})
+
+	index := map[string]int{}
+	for i, change := range changes {
+		index[change.Path] = i
+	}
+
+	ordered := make([]PlannedChange, 0, len(changes))
+	visited := map[string]bool{}
+
+	var visit func(change PlannedChange)
+	visit = func(change PlannedChange) {
+		if visited[change.Path] {
+			return
+		}
+		visited[change.Path] = true
+		for _, reference := range references(change) {
+			if i, ok := index[reference]; ok {
+				visit(changes[i])
+			}
+		}
+		ordered = append(ordered, change)
+	}
+
+	for _, change := range changes {
+		visit(change)
+	}
+
+	return ordered
+}
+
+// normalizeObject converts an object to its json representation without server managed fields
+func normalizeObject(object any) (any, error) {
+	encoded, err := json.Marshal(object)
+	if err != nil {
+		return nil, err
+	}
+
+	var decoded any
+	err = json.Unmarshal(encoded, &decoded)
+	if err != nil {
+		return nil, err
+	}
+
+	return stripServerManagedFields(decoded), nil
+}
+
+func stripServerManagedFields(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		for _, field := range ServerManagedFields {
+			delete(value, field)
+		}
+		for key, nested := range value {
+			if nested == nil {
+				delete(value, key)
+				continue
+			}
+			value[key] = stripServerManagedFields(nested)
+		}
+	case []any:
+		for i, nested := range value {
+			value[i] = stripServerManagedFields(nested)
+		}
+	}
+	return value
+}
+
+// diffObjects returns the fields set on desired that differ on live. Fields desired leaves
+// unset are not compared, so defaults filled in by NSX don't show up as changes.
+func diffObjects(desired, live any) ([]FieldChange, error) {
+	return compareObjects(desired, live, false)
+}
+
+// compareObjects compares two objects without their server managed fields, with all set
+// only the fields set on desired are compared, otherwise fields set on either side
+func compareObjects(desired, live any, all bool) ([]FieldChange, error) {
+	desiredValue, err := normalizeObject(desired)
+	if err != nil {
+		return nil, err
+	}
+	liveValue, err := normalizeObject(live)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &fieldDiffer{all: all}
+	d.values("", desiredValue, liveValue)
+	return d.changes, nil
+}
+
+func joinField(prefix, field string) string {
+	if prefix == "" {
+		return field
+	}
+	return prefix + "." + field
+}
+
+type fieldDiffer struct {
+	// compare fields only set on live as well
+	all     bool
+	changes []FieldChange
+}
+
+func (d *fieldDiffer) add(field string, live, desired any) {
+	d.changes = append(d.changes, FieldChange{Field: field, Old: live, New: desired})
+}
+
+func (d *fieldDiffer) values(field string, desired, live any) {
+	switch desiredValue := desired.(type) {
+	case map[string]any:
+		liveValue, ok := live.(map[string]any)
+		if !ok {
+			d.add(field, live, desired)
+			return
+		}
+		keys := make([]string, 0, len(desiredValue))
+		for key := range desiredValue {
+			keys = append(keys, key)
+		}
+		if d.all {
+			for key := range liveValue {
+				if _, ok := desiredValue[key]; !ok {
+					keys = append(keys, key)
+				}
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			d.values(joinField(field, key), desiredValue[key], liveValue[key])
+		}
+	case []any:
+		liveValue, ok := live.([]any)
+		if !ok {
+			d.add(field, live, desired)
+			return
+		}
+		d.lists(field, desiredValue, liveValue)
+	default:
+		if !reflect.DeepEqual(desired, live) {
+			d.add(field, live, desired)
+		}
+	}
+}
+
+// lists compares lists of objects with an id (like rules) by id, and other lists by position
+func (d *fieldDiffer) lists(field string, desired, live []any) {
+	desiredIds := listIds(desired)
+	if desiredIds == nil {
+		if len(desired) != len(live) {
+			d.add(field, live, desired)
+			return
+		}
+		for i := range desired {
+			d.values(fmt.Sprintf("%s[%d]", field, i), desired[i], live[i])
+		}
+		return
+	}
+
+	liveById := map[string]any{}
+	liveIds := listIds(live)
+	for i, id := range liveIds {
+		liveById[id] = live[i]
+	}
+
+	seen := map[string]bool{}
+	for i, id := range desiredIds {
+		seen[id] = true
+		elementField := fmt.Sprintf("%s[%s]", field, id)
+		liveElement, ok := liveById[id]
+		if !ok {
+			d.add(elementField, nil, desired[i])
+			continue
+		}
+		d.values(elementField, desired[i], liveElement)
+	}
+
+	for i, id := range liveIds {
+		if !seen[id] {
+			d.add(fmt.Sprintf("%s[%s]", field, id), live[i], nil)
+		}
+	}
+}
+
+// listIds returns the ids of a list of objects, or nil if any element has no id
+func listIds(list []any) []string {
+	if len(list) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(list))
+	for _, element := range list {
+		object, ok := element.(map[string]any)
+		if !ok {
+			return nil
+		}
+		id, ok := object["id"].(string)
+		if !ok {
+			return nil
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// PlanChanges fetches the live state from NSX and computes the plan to reach the desired state
+func PlanChanges(ctx context.Context, nsxConfig NSXClient, desired *ObjectSet, opts PlanOptions) (*Plan, error) {
+	live, err := FetchObjectSet(ctx, nsxConfig)
+	if err != nil {
+		return nil, err
+	}
+	return ComputePlan(desired, live, opts)
+}
+
+// ApplyError is returned when applying a plan fails, changes before Change were applied
+type ApplyError struct {
+	Change PlannedChange
+	Err    error
+}
+
+func (e *ApplyError) Error() string {
+	return fmt.Sprintf("error applying %s %s %s: %v", e.Change.Action, e.Change.Kind, e.Change.Path, e.Err)
+}
+
+func (e *ApplyError) Unwrap() error {
+	return e.Err
+}
+
+// Apply executes the plan in order, stopping at the first failing change. Updates send the
+// live revision, so an object modified since the plan was computed fails with a
+// RevisionConflictError instead of being overwritten.
+func (p *Plan) Apply(ctx context.Context, nsxConfig *NSXClient) error {
+	for _, change := range p.Changes {
+		err := applyChange(ctx, nsxConfig, change)
+		if err != nil {
+			return &ApplyError{Change: change, Err: err}
+		}
+	}
+	return nil
+}
+
+func applyChange(ctx context.Context, nsxConfig *NSXClient, change PlannedChange) error {
+	switch change.Action {
+	case ChangeNoop:
+		return nil
+	case ChangeDelete:
+		return deleteResource(ctx, nsxConfig, change.Path, nil)
+	}
+
+	var liveRevision *int32
+	if change.Live != nil {
+		liveRevision = change.Live.(policyResourcer).policyResource().Revision
+	}
+
+	switch desired := change.Desired.(type) {
+	case *Group:
+		group := *desired
+		group.Revision = liveRevision
+		return patchResource(ctx, nsxConfig, change.Path, &group, liveRevision)
+	case *Service:
+		service := *desired
+		service.Revision = liveRevision
+		return patchResource(ctx, nsxConfig, change.Path, &service, liveRevision)
+	case *SecurityPolicy:
+		policy := *desired
+		policy.Revision = liveRevision
+		err := patchResource(ctx, nsxConfig, change.Path, &policy, liveRevision)
+		if err != nil {
+			return err
+		}
+		return deleteRemovedRules(ctx, nsxConfig, change.Path, desired, change.Live)
+	}
+
+	return fmt.Errorf("unsupported object %T", change.Desired)
+}
+
+// deleteRemovedRules deletes the live rules of a policy that are not in the desired policy,
+// a PATCH of the policy only creates and updates rules
+func deleteRemovedRules(ctx context.Context, nsxConfig *NSXClient, policyPath string, desired *SecurityPolicy, live any) error {
+	livePolicy, _ := live.(*SecurityPolicy)
+	for _, ruleId := range removedRules(desired, livePolicy) {
+		err := deleteResource(ctx, nsxConfig, policyPath+"/rules/"+ruleId, nil)
+		if err != nil {
+			return fmt.Errorf("error deleting rule %s: %w", ruleId, err)
+		}
+	}
+	return nil
+}
+
+// removedRules returns the ids of the live rules that are not in the desired policy
+func removedRules(desired, live *SecurityPolicy) []string {
+	if live == nil {
+		return nil
+	}
+
+	desiredRules := map[string]bool{}
+	for _, rule := range desired.Rules {
+		if rule.Id != nil {
+			desiredRules[*rule.Id] = true
+		}
+	}
+
+	var removed []string
+	for _, rule := range live.Rules {
+		if rule.Id != nil && !desiredRules[*rule.Id] {
+			removed = append(removed, *rule.Id)
+		}
+	}
+	return removed
+}
@@ -0,0 +1,213 @@
+package gonsx
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func testGroup(id, displayName string, references ...string) Group {
+	group := Group{}
+	group.Id = stringPointer(id)
+	group.DisplayName = stringPointer(displayName)
+	if len(references) > 0 {
+		group.Expression = []DynamicExpressionWrapper{{Expression: PathExpression(references...)}}
+	}
+	return group
+}
+
+// setLiveFields sets the fields NSX adds to the objects it returns
+func setLiveFields(resource *BaseNsxPolicyApiResource, path string) {
+	revision := int32(3)
+	resource.Path = stringPointer(path)
+	resource.Revision = &revision
+}
+
+func liveGroup(group Group, path string) Group {
+	setLiveFields(&group.BaseNsxPolicyApiResource, path)
+	return group
+}
+
+func livePolicy(policy SecurityPolicy, path string) SecurityPolicy {
+	setLiveFields(&policy.BaseNsxPolicyApiResource, path)
+	return policy
+}
+
+func planSummary(plan *Plan) []string {
+	summary := make([]string, 0, len(plan.Changes))
+	for _, change := range plan.Changes {
+		summary = append(summary, string(change.Action)+" "+change.Path)
+	}
+	return summary
+}
+
+func managedByPrefix(resource *BaseNsxPolicyApiResource) bool {
+	return resource.Id != nil && strings.HasPrefix(*resource.Id, "app-")
+}
+
+func TestComputePlan(t *testing.T) {
+	webPath := GroupPath(DefaultDomainId, "app-web")
+	dbPath := GroupPath(DefaultDomainId, "app-db")
+	systemPath := GroupPath(DefaultDomainId, "system")
+
+	liveWeb := liveGroup(testGroup("app-web", "web"), webPath)
+	liveDb := liveGroup(testGroup("app-db", "db"), dbPath)
+	liveSystem := liveGroup(testGroup("system", "system"), systemPath)
+
+	tests := []struct {
+		name    string
+		desired ObjectSet
+		live    ObjectSet
+		opts    PlanOptions
+		want    []string
+	}{
+		{
+			name:    "create",
+			desired: ObjectSet{Groups: []Group{testGroup("app-web", "web")}},
+			want:    []string{"create " + webPath},
+		},
+		{
+			name:    "no-op ignores server managed fields",
+			desired: ObjectSet{Groups: []Group{testGroup("app-web", "web")}},
+			live:    ObjectSet{Groups: []Group{liveWeb}},
+			want:    []string{"no-op " + webPath},
+		},
+		{
+			name:    "update",
+			desired: ObjectSet{Groups: []Group{testGroup("app-web", "web servers")}},
+			live:    ObjectSet{Groups: []Group{liveWeb}},
+			want:    []string{"update " + webPath},
+		},
+		{
+			name:    "prune off keeps live objects",
+			desired: ObjectSet{Groups: []Group{testGroup("app-web", "web")}},
+			live:    ObjectSet{Groups: []Group{liveWeb, liveDb}},
+			want:    []string{"no-op " + webPath},
+		},
+		{
+			name:    "prune deletes managed live objects only",
+			desired: ObjectSet{Groups: []Group{testGroup("app-web", "web")}},
+			live:    ObjectSet{Groups: []Group{liveWeb, liveDb, liveSystem}},
+			opts:    PlanOptions{Prune: true, Managed: managedByPrefix},
+			want:    []string{"no-op " + webPath, "delete " + dbPath},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			plan, err := ComputePlan(&test.desired, &test.live, test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := planSummary(plan); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("plan = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestComputePlanUpdateChanges(t *testing.T) {
+	desired := &ObjectSet{Groups: []Group{testGroup("app-web", "web servers")}}
+	liveSet := &ObjectSet{Groups: []Group{liveGroup(testGroup("app-web", "web"), GroupPath(DefaultDomainId, "app-web"))}}
+
+	plan, err := ComputePlan(desired, liveSet, PlanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []FieldChange{{Field: "display_name", Old: "web", New: "web servers"}}
+	if !reflect.DeepEqual(plan.Changes[0].Changes, want) {
+		t.Errorf("changes = %v, want %v", plan.Changes[0].Changes, want)
+	}
+}
+
+func TestComputePlanPruneRequiresManaged(t *testing.T) {
+	_, err := ComputePlan(&ObjectSet{}, &ObjectSet{}, PlanOptions{Prune: true})
+	if err == nil {
+		t.Error("ComputePlan with Prune and no Managed succeeded, want an error")
+	}
+}
+
+func TestComputePlanOrder(t *testing.T) {
+	webPath := GroupPath(DefaultDomainId, "app-web")
+	allPath := GroupPath(DefaultDomainId, "app-all")
+	policyPath := SecurityPolicyPath(DefaultDomainId, "app-policy")
+
+	rule := testRule("allow-web", "ALLOW", 1)
+	rule.DestinationGroups = []string{webPath}
+	policy := testPolicy("app-policy", "Application", 1, rule)
+
+	// app-all sorts before app-web but references it
+	groups := []Group{testGroup("app-all", "all", webPath), testGroup("app-web", "web")}
+
+	t.Run("create referenced objects first", func(t *testing.T) {
+		desired := &ObjectSet{Policies: []SecurityPolicy{policy}, Groups: groups}
+
+		plan, err := ComputePlan(desired, &ObjectSet{}, PlanOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := []string{"create " + webPath, "create " + allPath, "create " + policyPath}
+		if got := planSummary(plan); !reflect.DeepEqual(got, want) {
+			t.Errorf("plan = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("delete referencing objects first", func(t *testing.T) {
+		liveSet := &ObjectSet{
+			Groups:   []Group{liveGroup(groups[1], webPath), liveGroup(groups[0], allPath)},
+			Policies: []SecurityPolicy{livePolicy(policy, policyPath)},
+		}
+
+		plan, err := ComputePlan(&ObjectSet{}, liveSet, PlanOptions{Prune: true, Managed: managedByPrefix})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := []string{"delete " + policyPath, "delete " + allPath, "delete " + webPath}
+		if got := planSummary(plan); !reflect.DeepEqual(got, want) {
+			t.Errorf("plan = %q, want %q", got, want)
+		}
+	})
+}
+
+func TestPlanApplyDeletesRemovedRules(t *testing.T) {
+	policyPath := SecurityPolicyPath(DefaultDomainId, "app-policy")
+
+	oldPolicy := testPolicy("app-policy", "Application", 1, testRule("keep", "ALLOW", 1), testRule("remove", "DROP", 2))
+	desiredPolicy := testPolicy("app-policy", "Application", 1, testRule("keep", "ALLOW", 1))
+
+	plan, err := ComputePlan(
+		&ObjectSet{Policies: []SecurityPolicy{desiredPolicy}},
+		&ObjectSet{Policies: []SecurityPolicy{livePolicy(oldPolicy, policyPath)}},
+		PlanOptions{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := planSummary(plan), []string{"update " + policyPath}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("plan = %q, want %q", got, want)
+	}
+
+	var mu sync.Mutex
+	var requests []string
+	nsxConfig := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, r.Method+" "+strings.TrimPrefix(r.URL.Path, PolicyApiPrefix))
+	}))
+
+	err = plan.Apply(context.Background(), &nsxConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"PATCH " + policyPath, "DELETE " + policyPath + "/rules/remove"}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("requests = %q, want %q", requests, want)
+	}
+}
@@ -0,0 +1,189 @@
+package gonsx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+)
+
+const (
+	PolicyApiPrefix  = "/policy/api/v1"
+	ManagerApiPrefix = "/api/v1"
+)
+
+// policyURL returns the full url for a policy path like /infra/domains/default
+func (nsxConfig *NSXClient) policyURL(path string, query url.Values) string {
+	u := fmt.Sprintf("https://%s%s%s", nsxConfig.Hostname, PolicyApiPrefix, path)
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return u
+}
+
+// managerURL returns the full url for a manager api path like /fabric/virtual-machines
+func (nsxConfig *NSXClient) managerURL(path string, query url.Values) string {
+	u := fmt.Sprintf("https://%s%s%s", nsxConfig.Hostname, ManagerApiPrefix, path)
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return u
+}
+
+// sendResource sends obj (if not nil) as json to the policy path and decodes the response into out (if not nil)
+func sendResource(ctx context.Context, nsxConfig *NSXClient, method, path string, query url.Values, obj any, out any) error {
+	return sendRequest(ctx, nsxConfig, method, nsxConfig.policyURL(path, query), obj, out)
+}
+
+// sendManagerResource is sendResource for the manager api
+func sendManagerResource(ctx context.Context, nsxConfig *NSXClient, method, path string, query url.Values, obj any, out any) error {
+	return sendRequest(ctx, nsxConfig, method, nsxConfig.managerURL(path, query), obj, out)
+}
+
+func sendRequest(ctx context.Context, nsxConfig *NSXClient, method, u string, obj any, out any) error {
+	var body io.Reader
+	if obj != nil {
+		payload, err := json.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := nsxConfig.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return err
+	}
+	if obj != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := nsxConfig.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(out)
+	if err != nil {
+		return fmt.Errorf("error decoding response: %T %v", out, err)
+	}
+
+	return nil
+}
+
+// getResource fetches a single object from a policy path
+func getResource[t NsxApiResource](ctx context.Context, nsxConfig *NSXClient, path string) (*t, error) {
+	var obj t
+	err := sendResource(ctx, nsxConfig, "GET", path, nil, nil, &obj)
+	if err != nil {
+		return nil, err
+	}
+	return &obj, nil
+}
+
+// listResources fetches all objects below a policy path, following the cursor page by page
+func listResources[t NsxApiResource](ctx context.Context, nsxConfig *NSXClient, path string) ([]t, error) {
+	return listPages[t](ctx, nsxConfig, nsxConfig.policyURL, path, url.Values{})
+}
+
+// listManagerResources is listResources for the manager api, query filters the list
+func listManagerResources[t NsxApiResource](ctx context.Context, nsxConfig *NSXClient, path string, query url.Values) ([]t, error) {
+	return listPages[t](ctx, nsxConfig, nsxConfig.managerURL, path, query)
+}
+
+func listPages[t NsxApiResource](ctx context.Context, nsxConfig *NSXClient, urlFor func(path string, query url.Values) string, path string, query url.Values) ([]t, error) {
+	results := []t{}
+	if query == nil {
+		query = url.Values{}
+	}
+
+	for {
+		page := NsxBulkResponse[t]{}
+		err := sendRequest(ctx, nsxConfig, "GET", urlFor(path, query), nil, &page)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, page.Results...)
+
+		if page.Cursor == nil || len(page.Results) == 0 || len(results) >= page.ResultCount {
+			return results, nil
+		}
+		query.Set("cursor", fmt.Sprint(int(*page.Cursor)))
+	}
+}
+
+// putResource replaces the object at a policy path, and returns the object as stored by NSX.
+// revision is only used to enrich a RevisionConflictError.
+func putResource[t NsxApiResource](ctx context.Context, nsxConfig *NSXClient, path string, obj *t, revision *int32) (*t, error) {
+	var stored t
+	err := sendResource(ctx, nsxConfig, "PUT", path, nil, obj, &stored)
+	if err != nil {
+		return nil, withRevision(err, path, revision)
+	}
+	return &stored, nil
+}
+
+// patchResource creates or partially updates the object at a policy path
+func patchResource(ctx context.Context, nsxConfig *NSXClient, path string, obj any, revision *int32) error {
+	err := sendResource(ctx, nsxConfig, "PATCH", path, nil, obj, nil)
+	return withRevision(err, path, revision)
+}
+
+// deleteResource deletes the object at a policy path
+func deleteResource(ctx context.Context, nsxConfig *NSXClient, path string, query url.Values) error {
+	return sendResource(ctx, nsxConfig, "DELETE", path, query, nil, nil)
+}
+
+// withRevision wraps a revision mismatch reported by NSX into a RevisionConflictError
+func withRevision(err error, path string, revision *int32) error {
+	var apiError *APIError
+	if errors.As(err, &apiError) && errors.Is(apiError, ErrRevisionMismatch) {
+		return &RevisionConflictError{Path: path, Revision: revision, Err: apiError}
+	}
+	return err
+}
+
+// RevisePosition is where action=revise moves a policy or rule, relative to the anchor
+type RevisePosition string
+
+const (
+	InsertTop    RevisePosition = "insert_top"
+	InsertBottom RevisePosition = "insert_bottom"
+	InsertBefore RevisePosition = "insert_before"
+	InsertAfter  RevisePosition = "insert_after"
+)
+
+// reviseResource POSTs obj with action=revise, letting NSX assign a sequence number that places
+// the object at the requested position. anchorPath is required for InsertBefore and InsertAfter.
+func reviseResource[t NsxApiResource](ctx context.Context, nsxConfig *NSXClient, path string, obj *t, position RevisePosition, anchorPath string) (*t, error) {
+	query := url.Values{}
+	query.Set("action", "revise")
+	query.Set("operation", string(position))
+
+	switch position {
+	case InsertBefore, InsertAfter:
+		if anchorPath == "" {
+			return nil, fmt.Errorf("an anchor path is required for %s", position)
+		}
+		query.Set("anchor_path", anchorPath)
+	case InsertTop, InsertBottom:
+	default:
+		return nil, fmt.Errorf("unknown revise position %q", position)
+	}
+
+	var stored t
+	err := sendResource(ctx, nsxConfig, "POST", path, query, obj, &stored)
+	if err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
@@ -0,0 +1,147 @@
+package gonsx
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy controls how NSXClient.Do retries throttled and failed requests
+type RetryPolicy struct {
+	// Maximum number of attempts, including the first one
+	MaxAttempts int
+	// Delay before the first retry, doubled on every following retry
+	BaseDelay time.Duration
+	// Upper bound for the computed delay including jitter, a Retry-After header sent by NSX is honored even if larger
+	MaxDelay time.Duration
+	// Fraction of the delay that is randomized, 0.2 spreads the delay over +/- 20%
+	Jitter float64
+	// HTTP status codes that are retried
+	RetryStatusCodes []int
+	// Retry when the connection is reset or closed before a response is received
+	RetryConnectionErrors bool
+}
+
+// DefaultRetryPolicy is used by NSXClient when no RetryPolicy is set
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:           10,
+	BaseDelay:             time.Second,
+	MaxDelay:              30 * time.Second,
+	Jitter:                0.2,
+	RetryStatusCodes:      []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+	RetryConnectionErrors: true,
+}
+
+func (nsxConfig *NSXClient) retryPolicy() *RetryPolicy {
+	if nsxConfig.RetryPolicy != nil {
+		return nsxConfig.RetryPolicy
+	}
+	return &DefaultRetryPolicy
+}
+
+// shouldRetry reports whether a request that ended with resp or err is worth retrying
+func (p *RetryPolicy) shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return p.RetryConnectionErrors && isConnectionError(err)
+	}
+
+	for _, statusCode := range p.RetryStatusCodes {
+		if resp.StatusCode == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// delay returns how long to wait before the given retry, 1 being the first retry
+func (p *RetryPolicy) delay(retry int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			return retryAfter
+		}
+	}
+
+	delay := float64(p.BaseDelay) * math.Pow(2, float64(retry-1))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (2*rand.Float64() - 1)
+		if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+			delay = float64(p.MaxDelay)
+		}
+	}
+
+	return time.Duration(delay)
+}
+
+// parseRetryAfter parses both forms of the Retry-After header, seconds and an HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+
+	return 0, false
+}
+
+func isConnectionError(err error) bool {
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// makeReplayable makes sure the request body can be sent again on a retry
+func makeReplayable(req *http.Request) error {
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody != nil {
+		return nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.Body, _ = req.GetBody()
+
+	return nil
+}
+
+// rewindBody resets the request body before a retry
+func rewindBody(req *http.Request) error {
+	if req.GetBody == nil {
+		return nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return err
+	}
+	req.Body = body
+
+	return nil
+}
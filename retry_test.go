@@ -0,0 +1,18 @@
+package gonsx
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryDelayIsCappedWithJitter(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: time.Second, MaxDelay: 4 * time.Second, Jitter: 0.5}
+
+	for retry := 1; retry <= 6; retry++ {
+		for i := 0; i < 100; i++ {
+			if delay := policy.delay(retry, nil); delay > policy.MaxDelay {
+				t.Fatalf("delay of retry %d is %v, more than MaxDelay %v", retry, delay, policy.MaxDelay)
+			}
+		}
+	}
+}
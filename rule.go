@@ -1,8 +1,10 @@
 package gonsx
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"strings"
 )
 
@@ -53,6 +55,65 @@ func (r Rule) ParentId() string {
 	return parentPathSplit[len(parentPathSplit)-1]
 }
 
+// RulePath returns the policy path of a rule in a security policy
+func RulePath(domainId, policyId, ruleId string) string {
+	return fmt.Sprintf("%s/rules/%s", SecurityPolicyPath(domainId, policyId), url.PathEscape(ruleId))
+}
+
+// GetRule fetches a single rule from a security policy
+func GetRule(ctx context.Context, nsxConfig *NSXClient, domainId, policyId, ruleId string) (*Rule, error) {
+	return getResource[Rule](ctx, nsxConfig, RulePath(domainId, policyId, ruleId))
+}
+
+// ListRules fetches all rules of a security policy
+func ListRules(ctx context.Context, nsxConfig *NSXClient, domainId, policyId string) ([]Rule, error) {
+	return listResources[Rule](ctx, nsxConfig, SecurityPolicyPath(domainId, policyId)+"/rules")
+}
+
+// CreateRule creates a new rule in an existing security policy, the rule Id must be set
+func CreateRule(ctx context.Context, nsxConfig *NSXClient, domainId, policyId string, rule *Rule) (*Rule, error) {
+	if rule.Id == nil {
+		return nil, fmt.Errorf("rule id is required to create a rule")
+	}
+	if rule.Revision != nil {
+		return nil, fmt.Errorf("rule %s has a revision, use UpdateRule to modify an existing rule", *rule.Id)
+	}
+	return putResource(ctx, nsxConfig, RulePath(domainId, policyId, *rule.Id), rule, nil)
+}
+
+// UpdateRule replaces an existing rule, the rule Revision must be the revision last read from NSX
+func UpdateRule(ctx context.Context, nsxConfig *NSXClient, domainId, policyId string, rule *Rule) (*Rule, error) {
+	if rule.Id == nil {
+		return nil, fmt.Errorf("rule id is required to update a rule")
+	}
+	if rule.Revision == nil {
+		return nil, fmt.Errorf("rule %s has no revision, use CreateRule to create a new rule", *rule.Id)
+	}
+	return putResource(ctx, nsxConfig, RulePath(domainId, policyId, *rule.Id), rule, rule.Revision)
+}
+
+// PatchRule creates a rule, or updates only the fields set on an existing rule
+func PatchRule(ctx context.Context, nsxConfig *NSXClient, domainId, policyId string, rule *Rule) error {
+	if rule.Id == nil {
+		return fmt.Errorf("rule id is required to patch a rule")
+	}
+	return patchResource(ctx, nsxConfig, RulePath(domainId, policyId, *rule.Id), rule, rule.Revision)
+}
+
+// DeleteRule deletes a single rule from a security policy
+func DeleteRule(ctx context.Context, nsxConfig *NSXClient, domainId, policyId, ruleId string) error {
+	return deleteResource(ctx, nsxConfig, RulePath(domainId, policyId, ruleId), nil)
+}
+
+// ReviseRule moves a rule within its security policy, anchorPath is the path of the rule
+// to insert before or after and is ignored for InsertTop and InsertBottom
+func ReviseRule(ctx context.Context, nsxConfig *NSXClient, domainId, policyId string, rule *Rule, position RevisePosition, anchorPath string) (*Rule, error) {
+	if rule.Id == nil {
+		return nil, fmt.Errorf("rule id is required to revise a rule")
+	}
+	return reviseResource(ctx, nsxConfig, RulePath(domainId, policyId, *rule.Id), rule, position, anchorPath)
+}
+
 type ServiceEntry struct {
 	BaseNsxPolicyApiResource
 }
@@ -0,0 +1,130 @@
+package gonsx
+
+import (
+	"context"
+	"fmt"
+)
+
+// ConsistentSearchOptions controls SearchAllConsistent
+type ConsistentSearchOptions struct {
+	// When the result count drifts between pages, fetch everything again by following the
+	// cursor returned with each page one by one, instead of returning a ResultCountDriftError
+	SequentialFallback bool
+}
+
+// ResultCountDriftError is returned when objects were created or deleted while paging through
+// a search, so the pages fetched in parallel may contain duplicates or gaps
+type ResultCountDriftError struct {
+	// Result count reported with the first page
+	InitialCount int
+	// Result count reported with the drifted page
+	Count int
+	// Cursor of the drifted page
+	Cursor int
+}
+
+func (e *ResultCountDriftError) Error() string {
+	return fmt.Sprintf("search result count drifted from %d to %d at cursor %d, objects changed while paging", e.InitialCount, e.Count, e.Cursor)
+}
+
+// policyResourcer is implemented by pointers to all types embedding BaseNsxPolicyApiResource
+type policyResourcer interface {
+	policyResource() *BaseNsxPolicyApiResource
+}
+
+// resourceKey returns the key used to dedupe search results, the path if known, else the id
+func resourceKey(item any) string {
+	base, ok := item.(policyResourcer)
+	if !ok {
+		return ""
+	}
+	resource := base.policyResource()
+	if resource.Path != nil {
+		return *resource.Path
+	}
+	if resource.Id != nil {
+		return *resource.Id
+	}
+	return ""
+}
+
+// policyResource gives generic code access to the common fields of any policy resource
+func (b *BaseNsxPolicyApiResource) policyResource() *BaseNsxPolicyApiResource {
+	return b
+}
+
+// dedupeResults removes results seen before, keeping the first occurrence and the order
+func dedupeResults[t NsxApiResource](results []t) []t {
+	seen := make(map[string]bool, len(results))
+	deduped := make([]t, 0, len(results))
+
+	for i := range results {
+		key := resourceKey(&results[i])
+		if key != "" {
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+		deduped = append(deduped, results[i])
+	}
+
+	return deduped
+}
+
+// SearchAllConsistent is like SearchAll, but returns the results in page order, deduplicated
+// by Path (or Id), and checks that the result count did not change while the pages were
+// fetched. For an order that is also stable across runs, set SortBy on the query.
+func SearchAllConsistent[t NsxApiResource](ctx context.Context, nsxConfig NSXClient, query *SearchQuery, opts ConsistentSearchOptions) ([]t, error) {
+	firstPage, err := SearchPage[t](ctx, nsxConfig, query, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if firstPage.Cursor == nil || len(firstPage.Results) >= firstPage.ResultCount {
+		return dedupeResults(firstPage.Results), nil
+	}
+
+	var cursorList []int
+	for i := int(*firstPage.Cursor); i < firstPage.ResultCount; i += query.pageSize() {
+		cursorList = append(cursorList, i)
+	}
+
+	pages, err := fetchPagesInOrder[t](ctx, nsxConfig, query, cursorList)
+	if err != nil {
+		return nil, err
+	}
+
+	results := firstPage.Results
+	for i, page := range pages {
+		if page.ResultCount != firstPage.ResultCount {
+			drift := &ResultCountDriftError{InitialCount: firstPage.ResultCount, Count: page.ResultCount, Cursor: cursorList[i]}
+			if !opts.SequentialFallback {
+				return nil, drift
+			}
+			nsxConfig.logf("%v, falling back to sequential paging", drift)
+			return searchAllSequential[t](ctx, nsxConfig, query)
+		}
+		results = append(results, page.Results...)
+	}
+
+	return dedupeResults(results), nil
+}
+
+// searchAllSequential fetches all pages one by one, following the cursor returned with each page
+func searchAllSequential[t NsxApiResource](ctx context.Context, nsxConfig NSXClient, query *SearchQuery) ([]t, error) {
+	results := []t{}
+
+	it := NewSearchIterator[t](ctx, nsxConfig, query)
+	defer it.Close()
+
+	for it.NextPage() {
+		results = append(results, it.Page()...)
+	}
+
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	return dedupeResults(results), nil
+}
@@ -0,0 +1,158 @@
+package gonsx
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// SearchIterator streams the results of a search query, holding only a single page in memory.
+// Results are consumed either item by item with Next and Item, or page by page with NextPage
+// and Page, the two styles must not be mixed on the same iterator.
+//
+//	it := NewSearchIterator[VirtualMachine](ctx, nsxConfig, NewSearchQuery(ResourceTypeIs("VirtualMachine")))
+//	defer it.Close()
+//	for it.Next() {
+//		vm := it.Item()
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type SearchIterator[t NsxApiResource] struct {
+	ctx       context.Context
+	cancel    context.CancelFunc
+	nsxConfig NSXClient
+	query     *SearchQuery
+
+	cursor      int
+	lastPage    bool
+	page        []t
+	index       int
+	fetched     int
+	resultCount int
+	// set by Close, which may run on another goroutine than Next
+	closed atomic.Bool
+	err    error
+}
+
+// NewSearchIterator creates an iterator over the results of query, no request is sent until
+// the first call to Next or NextPage
+func NewSearchIterator[t NsxApiResource](ctx context.Context, nsxConfig NSXClient, query *SearchQuery) *SearchIterator[t] {
+	ctx, cancel := context.WithCancel(ctx)
+	return &SearchIterator[t]{
+		ctx:       ctx,
+		cancel:    cancel,
+		nsxConfig: nsxConfig,
+		query:     query,
+		index:     -1,
+	}
+}
+
+// fetch loads the next page, returning false when there are no more pages or on error
+func (it *SearchIterator[t]) fetch() bool {
+	if it.lastPage || it.err != nil || it.closed.Load() {
+		return false
+	}
+
+	bulkResponse, err := SearchPage[t](it.ctx, it.nsxConfig, it.query, it.cursor)
+	if err != nil {
+		it.err = err
+		it.page = nil
+		return false
+	}
+
+	it.page = bulkResponse.Results
+	it.index = -1
+	it.fetched += len(bulkResponse.Results)
+	it.resultCount = bulkResponse.ResultCount
+
+	if bulkResponse.Cursor == nil || len(bulkResponse.Results) == 0 || it.fetched >= bulkResponse.ResultCount {
+		it.lastPage = true
+	} else {
+		it.cursor = int(*bulkResponse.Cursor)
+	}
+
+	return len(it.page) > 0
+}
+
+// Next advances to the next result, fetching the next page when needed. It returns false
+// when all results were read, the iterator was closed, or an error occurred.
+func (it *SearchIterator[t]) Next() bool {
+	if it.closed.Load() {
+		return false
+	}
+	it.index++
+	for it.index >= len(it.page) {
+		if !it.fetch() {
+			return false
+		}
+		it.index = 0
+	}
+	return true
+}
+
+// Item returns the current result, only valid after Next returned true
+func (it *SearchIterator[t]) Item() t {
+	return it.page[it.index]
+}
+
+// NextPage advances to the next page of results. It returns false when all pages were read,
+// the iterator was closed, or an error occurred.
+func (it *SearchIterator[t]) NextPage() bool {
+	return it.fetch()
+}
+
+// Page returns the results of the current page, only valid after NextPage returned true
+func (it *SearchIterator[t]) Page() []t {
+	return it.page
+}
+
+// ResultCount returns the total number of results reported by NSX with the last page
+func (it *SearchIterator[t]) ResultCount() int {
+	return it.resultCount
+}
+
+// Err returns the error that stopped the iteration, if any. Stopping with Close is not an error.
+func (it *SearchIterator[t]) Err() error {
+	if it.closed.Load() {
+		return nil
+	}
+	return it.err
+}
+
+// Close stops the iteration early, cancelling a page fetch in flight. It is safe to call
+// from another goroutine than the one calling Next.
+func (it *SearchIterator[t]) Close() {
+	it.closed.Store(true)
+	it.cancel()
+}
+
+// SearchStream sends the results of query one by one on the returned channel, fetching a page
+// only when the previous one was consumed. The results channel is closed when all results were
+// sent, ctx is done, or an error occurred, which is then sent on the error channel.
+func SearchStream[t NsxApiResource](ctx context.Context, nsxConfig NSXClient, query *SearchQuery) (<-chan t, <-chan error) {
+	results := make(chan t)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(results)
+		defer close(errs)
+
+		it := NewSearchIterator[t](ctx, nsxConfig, query)
+		defer it.Close()
+
+		for it.Next() {
+			select {
+			case results <- it.Item():
+			case <-ctx.Done():
+				errs <- ctx.Err()
+				return
+			}
+		}
+
+		if err := it.Err(); err != nil {
+			errs <- err
+		}
+	}()
+
+	return results, errs
+}
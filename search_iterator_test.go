@@ -0,0 +1,42 @@
+package gonsx
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestSearchIteratorCloseDuringFetch(t *testing.T) {
+	secondPage := make(chan struct{})
+	nsxConfig := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("cursor") == "0" {
+			w.Write([]byte(`{"result_count": 2, "cursor": "1", "results": [{"external_id": "vm-1"}]}`))
+			return
+		}
+		close(secondPage)
+		<-r.Context().Done()
+	}))
+
+	it := NewSearchIterator[VirtualMachine](context.Background(), nsxConfig, NewSearchQuery(ResourceTypeIs("VirtualMachine")))
+	if !it.Next() || it.Item().ExternalId != "vm-1" {
+		t.Fatalf("first result missing, err %v", it.Err())
+	}
+
+	next := make(chan bool)
+	go func() {
+		next <- it.Next()
+	}()
+
+	<-secondPage
+	it.Close()
+
+	if <-next {
+		t.Error("Next returned true after Close")
+	}
+	if err := it.Err(); err != nil {
+		t.Errorf("Err = %v after Close, want nil", err)
+	}
+	if it.Next() {
+		t.Error("Next returned true on a closed iterator")
+	}
+}
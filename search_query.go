@@ -0,0 +1,188 @@
+package gonsx
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// SearchClause is a Lucene style clause for the query parameter of the Policy search API
+type SearchClause string
+
+// luceneSpecialChars are escaped in values, the wildcards * and ? are handled separately
+const luceneSpecialChars = `+-&|!(){}[]^"~:\/ `
+
+// escapeSearchValue escapes a value so it is matched literally
+func escapeSearchValue(value string) string {
+	return escapeSearchPattern(strings.NewReplacer("*", `\*`, "?", `\?`).Replace(value))
+}
+
+// escapeSearchPattern escapes a value but keeps the * and ? wildcards, an already
+// escaped \* or \? stays escaped
+func escapeSearchPattern(pattern string) string {
+	var sb strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		if c == '\\' && i+1 < len(pattern) && (pattern[i+1] == '*' || pattern[i+1] == '?') {
+			sb.WriteByte(c)
+			sb.WriteByte(pattern[i+1])
+			i++
+			continue
+		}
+		if strings.IndexByte(luceneSpecialChars, c) >= 0 {
+			sb.WriteByte('\\')
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
+
+// FieldEquals matches objects whose field has exactly the given value
+func FieldEquals(field, value string) SearchClause {
+	return SearchClause(fmt.Sprintf("%s:%s", field, escapeSearchValue(value)))
+}
+
+// FieldLike matches objects whose field matches a pattern with * and ? wildcards
+func FieldLike(field, pattern string) SearchClause {
+	return SearchClause(fmt.Sprintf("%s:%s", field, escapeSearchPattern(pattern)))
+}
+
+// ResourceTypeIs matches objects of a resource type, e.g. Group or VirtualMachine
+func ResourceTypeIs(resourceType string) SearchClause {
+	return FieldEquals("resource_type", resourceType)
+}
+
+// DisplayNameLike matches objects whose display name matches a pattern with * and ? wildcards
+func DisplayNameLike(pattern string) SearchClause {
+	return FieldLike("display_name", pattern)
+}
+
+// PathPrefix matches objects whose policy path starts with prefix
+func PathPrefix(prefix string) SearchClause {
+	return SearchClause(fmt.Sprintf("path:%s*", escapeSearchValue(prefix)))
+}
+
+// MarkedForDelete matches objects on their marked_for_delete flag
+func MarkedForDelete(marked bool) SearchClause {
+	return SearchClause(fmt.Sprintf("marked_for_delete:%t", marked))
+}
+
+// TagIs matches objects tagged with scope|tag. An empty scope or tag matches any value for it.
+// The scope and tag are matched independently, so an object with the scope on one tag and the
+// tag value on another tag matches too. Check the tags of the results when that matters.
+func TagIs(scope, tag string) SearchClause {
+	var clauses []SearchClause
+	if scope != "" {
+		clauses = append(clauses, FieldEquals("tags.scope", scope))
+	}
+	if tag != "" {
+		clauses = append(clauses, FieldEquals("tags.tag", tag))
+	}
+	if len(clauses) == 0 {
+		return SearchClause("_exists_:tags")
+	}
+	return SearchAnd(clauses...)
+}
+
+// SearchAnd matches objects matching all clauses
+func SearchAnd(clauses ...SearchClause) SearchClause {
+	return joinClauses("AND", clauses)
+}
+
+// SearchOr matches objects matching any of the clauses
+func SearchOr(clauses ...SearchClause) SearchClause {
+	return joinClauses("OR", clauses)
+}
+
+// SearchNot matches objects not matching the clause
+func SearchNot(clause SearchClause) SearchClause {
+	return SearchClause(fmt.Sprintf("NOT (%s)", clause))
+}
+
+func joinClauses(operator string, clauses []SearchClause) SearchClause {
+	parts := make([]string, 0, len(clauses))
+	for _, clause := range clauses {
+		if clause != "" {
+			parts = append(parts, string(clause))
+		}
+	}
+
+	switch len(parts) {
+	case 0:
+		return ""
+	case 1:
+		return SearchClause(parts[0])
+	}
+	return SearchClause("(" + strings.Join(parts, " "+operator+" ") + ")")
+}
+
+// SearchQuery is a query for the Policy search API, with paging and sorting options
+type SearchQuery struct {
+	// Clause the results must match
+	Query SearchClause
+	// Field to sort the results by
+	SortBy string
+	// Sort direction, NSX sorts ascending when nil
+	SortAscending *bool
+	// Only return these fields of each result, all fields are returned when empty
+	IncludedFields []string
+	// Results per page, SearchPageSize is used when 0
+	PageSize int
+}
+
+// NewSearchQuery creates a query matching all of the clauses
+func NewSearchQuery(clauses ...SearchClause) *SearchQuery {
+	return &SearchQuery{Query: SearchAnd(clauses...)}
+}
+
+// Sort sorts the results by a field
+func (q *SearchQuery) Sort(field string, ascending bool) *SearchQuery {
+	q.SortBy = field
+	q.SortAscending = &ascending
+	return q
+}
+
+// Include limits the fields returned for each result
+func (q *SearchQuery) Include(fields ...string) *SearchQuery {
+	q.IncludedFields = append(q.IncludedFields, fields...)
+	return q
+}
+
+// WithPageSize sets the number of results per page
+func (q *SearchQuery) WithPageSize(pageSize int) *SearchQuery {
+	q.PageSize = pageSize
+	return q
+}
+
+func (q *SearchQuery) pageSize() int {
+	if q.PageSize > 0 {
+		return q.PageSize
+	}
+	return SearchPageSize
+}
+
+// Values returns the url query parameters to fetch the page at cursor
+func (q *SearchQuery) Values(cursor int) url.Values {
+	values := url.Values{}
+	values.Set("query", string(q.Query))
+	values.Set("page_size", strconv.Itoa(q.pageSize()))
+	values.Set("cursor", strconv.Itoa(cursor))
+
+	if q.SortBy != "" {
+		values.Set("sort_by", q.SortBy)
+	}
+	if q.SortAscending != nil {
+		values.Set("sort_ascending", strconv.FormatBool(*q.SortAscending))
+	}
+	if len(q.IncludedFields) > 0 {
+		values.Set("included_fields", strings.Join(q.IncludedFields, ","))
+	}
+
+	return values
+}
+
+// URL returns the full search url to fetch the page at cursor
+func (q *SearchQuery) URL(hostname string, cursor int) string {
+	return fmt.Sprintf("https://%s%s?%s", hostname, SearchEndpoint, q.Values(cursor).Encode())
+}
@@ -1,5 +1,11 @@
 package gonsx
 
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
 type SecurityPolicy struct {
 	BaseNsxPolicyApiResource
 	// - Distributed Firewall - Policy framework provides five pre-defined categories for classifying a security policy. They are \"Ethernet\",\"Emergency\", \"Infrastructure\" \"Environment\" and \"Application\". There is a pre-determined order in which the policy framework manages the priority of these security policies. Ethernet category is for supporting layer 2 firewall rules. The other four categories are applicable for layer 3 rules. Amongst them, the Emergency category has the highest priority followed by Infrastructure, Environment and then Application rules. Administrator can choose to categorize a security policy into the above categories or can choose to leave it empty. If empty it will have the least precedence w.r.t the above four categories. - Edge Firewall - Policy Framework for Edge Firewall provides six pre-defined categories \"Emergency\", \"SystemRules\", \"SharedPreRules\", \"LocalGatewayRules\", \"AutoServiceRules\" and \"Default\", in order of priority of rules. All categories are allowed for Gatetway Policies that belong to 'default' Domain. However, for user created domains, category is restricted to \"SharedPreRules\" or \"LocalGatewayRules\" only. Also, the users can add/modify/delete rules from only the \"SharedPreRules\" and \"LocalGatewayRules\" categories. If user doesn't specify the category then defaulted to \"Rules\". System generated category is used by NSX created rules, for example BFD rules. Autoplumbed category used by NSX verticals to autoplumb data path rules. Finally, \"Default\" category is the placeholder default rules with lowest in the order of priority.
@@ -48,3 +54,64 @@ type ApplicationConnectivityStrategy struct {
 	RuleId         *int64 `json:"default_application_rule_id,omitempty"`
 	LoggingEnabled *bool  `json:"logging_enabled,omitempty"`
 }
+
+// SecurityPolicyPath returns the policy path of a security policy in a domain
+func SecurityPolicyPath(domainId, policyId string) string {
+	return fmt.Sprintf("/infra/domains/%s/security-policies/%s", url.PathEscape(domainId), url.PathEscape(policyId))
+}
+
+// GetSecurityPolicy fetches a single security policy, including its rules
+func GetSecurityPolicy(ctx context.Context, nsxConfig *NSXClient, domainId, policyId string) (*SecurityPolicy, error) {
+	return getResource[SecurityPolicy](ctx, nsxConfig, SecurityPolicyPath(domainId, policyId))
+}
+
+// ListSecurityPolicies fetches all security policies in a domain, rules are not included
+func ListSecurityPolicies(ctx context.Context, nsxConfig *NSXClient, domainId string) ([]SecurityPolicy, error) {
+	return listResources[SecurityPolicy](ctx, nsxConfig, fmt.Sprintf("/infra/domains/%s/security-policies", url.PathEscape(domainId)))
+}
+
+// CreateSecurityPolicy creates a new security policy together with its embedded Rules, the policy Id must be set
+func CreateSecurityPolicy(ctx context.Context, nsxConfig *NSXClient, domainId string, policy *SecurityPolicy) (*SecurityPolicy, error) {
+	if policy.Id == nil {
+		return nil, fmt.Errorf("security policy id is required to create a security policy")
+	}
+	if policy.Revision != nil {
+		return nil, fmt.Errorf("security policy %s has a revision, use UpdateSecurityPolicy to modify an existing security policy", *policy.Id)
+	}
+	return putResource(ctx, nsxConfig, SecurityPolicyPath(domainId, *policy.Id), policy, nil)
+}
+
+// UpdateSecurityPolicy replaces an existing security policy and its embedded Rules. Rules missing
+// from the policy are deleted. The policy Revision must be the revision last read from NSX.
+func UpdateSecurityPolicy(ctx context.Context, nsxConfig *NSXClient, domainId string, policy *SecurityPolicy) (*SecurityPolicy, error) {
+	if policy.Id == nil {
+		return nil, fmt.Errorf("security policy id is required to update a security policy")
+	}
+	if policy.Revision == nil {
+		return nil, fmt.Errorf("security policy %s has no revision, use CreateSecurityPolicy to create a new security policy", *policy.Id)
+	}
+	return putResource(ctx, nsxConfig, SecurityPolicyPath(domainId, *policy.Id), policy, policy.Revision)
+}
+
+// PatchSecurityPolicy creates a security policy, or updates only the fields set on an existing one.
+// Embedded Rules are created or patched, rules missing from the policy are left untouched.
+func PatchSecurityPolicy(ctx context.Context, nsxConfig *NSXClient, domainId string, policy *SecurityPolicy) error {
+	if policy.Id == nil {
+		return fmt.Errorf("security policy id is required to patch a security policy")
+	}
+	return patchResource(ctx, nsxConfig, SecurityPolicyPath(domainId, *policy.Id), policy, policy.Revision)
+}
+
+// DeleteSecurityPolicy deletes a security policy and all of its rules
+func DeleteSecurityPolicy(ctx context.Context, nsxConfig *NSXClient, domainId, policyId string) error {
+	return deleteResource(ctx, nsxConfig, SecurityPolicyPath(domainId, policyId), nil)
+}
+
+// ReviseSecurityPolicy moves a security policy within its category, anchorPath is the path of
+// the security policy to insert before or after and is ignored for InsertTop and InsertBottom
+func ReviseSecurityPolicy(ctx context.Context, nsxConfig *NSXClient, domainId string, policy *SecurityPolicy, position RevisePosition, anchorPath string) (*SecurityPolicy, error) {
+	if policy.Id == nil {
+		return nil, fmt.Errorf("security policy id is required to revise a security policy")
+	}
+	return reviseResource(ctx, nsxConfig, SecurityPolicyPath(domainId, *policy.Id), policy, position, anchorPath)
+}
@@ -0,0 +1,148 @@
+package gonsx
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+type Service struct {
+	BaseNsxPolicyApiResource
+	// The broad category to which the service belongs
+	ServiceType *string `json:"service_type,omitempty"`
+	// The flag to indicate if service is default.
+	IsDefault *bool `json:"is_default,omitempty"`
+	// Service entries, defining the protocols and ports of the service
+	ServiceEntries []DynamicServiceEntryWrapper `json:"service_entries,omitempty"`
+}
+
+func (s *Service) String() string {
+	if s == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf(`Service: %s`, *s.DisplayName)
+}
+
+// ServicePath returns the policy path of a service
+func ServicePath(serviceId string) string {
+	return fmt.Sprintf("/infra/services/%s", url.PathEscape(serviceId))
+}
+
+// ServiceEntryPath returns the policy path of a service entry
+func ServiceEntryPath(serviceId, serviceEntryId string) string {
+	return fmt.Sprintf("%s/service-entries/%s", ServicePath(serviceId), url.PathEscape(serviceEntryId))
+}
+
+// GetService fetches a single service, including its service entries
+func GetService(ctx context.Context, nsxConfig *NSXClient, serviceId string) (*Service, error) {
+	return getResource[Service](ctx, nsxConfig, ServicePath(serviceId))
+}
+
+// GetServiceByPath fetches a service by its policy path, as referenced by Rule.Services
+func GetServiceByPath(ctx context.Context, nsxConfig *NSXClient, path string) (*Service, error) {
+	if !strings.Contains(path, "/services/") {
+		return nil, fmt.Errorf("%q is not a service path", path)
+	}
+	return getResource[Service](ctx, nsxConfig, path)
+}
+
+// ListServices fetches all services, including the predefined system services
+func ListServices(ctx context.Context, nsxConfig *NSXClient) ([]Service, error) {
+	return listResources[Service](ctx, nsxConfig, "/infra/services")
+}
+
+// CreateService creates a new service together with its ServiceEntries, the service Id must be set
+func CreateService(ctx context.Context, nsxConfig *NSXClient, service *Service) (*Service, error) {
+	if service.Id == nil {
+		return nil, fmt.Errorf("service id is required to create a service")
+	}
+	if service.Revision != nil {
+		return nil, fmt.Errorf("service %s has a revision, use UpdateService to modify an existing service", *service.Id)
+	}
+	return putResource(ctx, nsxConfig, ServicePath(*service.Id), service, nil)
+}
+
+// UpdateService replaces an existing service and its ServiceEntries, the service Revision
+// must be the revision last read from NSX
+func UpdateService(ctx context.Context, nsxConfig *NSXClient, service *Service) (*Service, error) {
+	if service.Id == nil {
+		return nil, fmt.Errorf("service id is required to update a service")
+	}
+	if service.Revision == nil {
+		return nil, fmt.Errorf("service %s has no revision, use CreateService to create a new service", *service.Id)
+	}
+	return putResource(ctx, nsxConfig, ServicePath(*service.Id), service, service.Revision)
+}
+
+// PatchService creates a service, or updates only the fields set on an existing service
+func PatchService(ctx context.Context, nsxConfig *NSXClient, service *Service) error {
+	if service.Id == nil {
+		return fmt.Errorf("service id is required to patch a service")
+	}
+	return patchResource(ctx, nsxConfig, ServicePath(*service.Id), service, service.Revision)
+}
+
+// DeleteService deletes a service, NSX refuses to delete services still used by rules
+func DeleteService(ctx context.Context, nsxConfig *NSXClient, serviceId string) error {
+	return deleteResource(ctx, nsxConfig, ServicePath(serviceId), nil)
+}
+
+// ListServiceEntries fetches the service entries of a service
+func ListServiceEntries(ctx context.Context, nsxConfig *NSXClient, serviceId string) ([]DynamicServiceEntryWrapper, error) {
+	type serviceEntryResults struct {
+		Results []DynamicServiceEntryWrapper `json:"results"`
+	}
+
+	results := serviceEntryResults{}
+	err := sendResource(ctx, nsxConfig, "GET", ServicePath(serviceId)+"/service-entries", nil, nil, &results)
+	if err != nil {
+		return nil, err
+	}
+
+	return results.Results, nil
+}
+
+// GetServiceEntry fetches a single service entry of a service
+func GetServiceEntry(ctx context.Context, nsxConfig *NSXClient, serviceId, serviceEntryId string) (*DynamicServiceEntryWrapper, error) {
+	serviceEntry := DynamicServiceEntryWrapper{}
+	err := sendResource(ctx, nsxConfig, "GET", ServiceEntryPath(serviceId, serviceEntryId), nil, nil, &serviceEntry)
+	if err != nil {
+		return nil, err
+	}
+	return &serviceEntry, nil
+}
+
+// PatchServiceEntry creates or updates a single service entry of a service, the entry Id must be set
+func PatchServiceEntry(ctx context.Context, nsxConfig *NSXClient, serviceId string, serviceEntry *DynamicServiceEntryWrapper) error {
+	base, ok := serviceEntry.ServiceEntry.(policyResourcer)
+	if !ok || base.policyResource().Id == nil {
+		return fmt.Errorf("service entry id is required to patch a service entry")
+	}
+	resource := base.policyResource()
+	return patchResource(ctx, nsxConfig, ServiceEntryPath(serviceId, *resource.Id), serviceEntry, resource.Revision)
+}
+
+// DeleteServiceEntry deletes a single service entry of a service
+func DeleteServiceEntry(ctx context.Context, nsxConfig *NSXClient, serviceId, serviceEntryId string) error {
+	return deleteResource(ctx, nsxConfig, ServiceEntryPath(serviceId, serviceEntryId), nil)
+}
+
+// GetServices resolves the service paths of the rule into services, ANY is skipped
+func (r Rule) GetServices(ctx context.Context, nsxConfig *NSXClient) ([]Service, error) {
+	services := []Service{}
+
+	for _, path := range r.Services {
+		if strings.EqualFold(path, "ANY") {
+			continue
+		}
+
+		service, err := GetServiceByPath(ctx, nsxConfig, path)
+		if err != nil {
+			return nil, fmt.Errorf("error getting service %s: %w", path, err)
+		}
+		services = append(services, *service)
+	}
+
+	return services, nil
+}
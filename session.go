@@ -0,0 +1,165 @@
+package gonsx
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+const (
+	SessionCreateEndpoint  = "/api/session/create"
+	SessionDestroyEndpoint = "/api/session/destroy"
+	SessionCookieName      = "JSESSIONID"
+	XsrfTokenHeader        = "X-XSRF-TOKEN"
+)
+
+// SessionAuth authenticates once against /api/session/create and reuses the JSESSIONID cookie
+// and X-XSRF-TOKEN header for all following requests, instead of sending basic auth on every
+// request. An expired session is re-created transparently.
+type SessionAuth struct {
+	Username string
+	Password string
+
+	mu      sync.Mutex
+	session *nsxSession
+}
+
+type nsxSession struct {
+	cookie    *http.Cookie
+	xsrfToken string
+}
+
+// current returns the active session, creating one if there is none
+func (s *SessionAuth) current(ctx context.Context, nsxConfig *NSXClient) (*nsxSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.session != nil {
+		return s.session, nil
+	}
+
+	session, err := s.create(ctx, nsxConfig)
+	if err != nil {
+		return nil, err
+	}
+	s.session = session
+
+	return session, nil
+}
+
+// create logs in with the credentials and returns the new session
+func (s *SessionAuth) create(ctx context.Context, nsxConfig *NSXClient) (*nsxSession, error) {
+	form := url.Values{}
+	form.Set("j_username", s.Username)
+	form.Set("j_password", s.Password)
+
+	requestURI := fmt.Sprintf("https://%s%s", nsxConfig.Hostname, SessionCreateEndpoint)
+	req, err := http.NewRequestWithContext(ctx, "POST", requestURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := nsxConfig.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("error creating session: %w", newAPIError(resp))
+	}
+
+	session := &nsxSession{xsrfToken: resp.Header.Get(XsrfTokenHeader)}
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == SessionCookieName {
+			session.cookie = cookie
+		}
+	}
+
+	if session.cookie == nil {
+		return nil, fmt.Errorf("error creating session: no %s cookie in response", SessionCookieName)
+	}
+
+	return session, nil
+}
+
+// apply adds the session cookie and xsrf token to the request
+func (session *nsxSession) apply(req *http.Request) {
+	req.Header.Del("Authorization")
+	req.Header.Del("Cookie")
+	req.AddCookie(&http.Cookie{Name: session.cookie.Name, Value: session.cookie.Value})
+	if session.xsrfToken != "" {
+		req.Header.Set(XsrfTokenHeader, session.xsrfToken)
+	}
+}
+
+// Authenticate adds the session cookie and xsrf token to the request, logging in first if needed
+func (s *SessionAuth) Authenticate(req *http.Request, nsxConfig *NSXClient) error {
+	session, err := s.current(req.Context(), nsxConfig)
+	if err != nil {
+		return err
+	}
+	session.apply(req)
+	return nil
+}
+
+// Reauthenticate re-creates the session used for req, after NSX rejected it as expired. When
+// another request already re-created the session in the meantime, that session is reused.
+func (s *SessionAuth) Reauthenticate(req *http.Request, nsxConfig *NSXClient) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.session == nil || s.session.cookie.Value == sessionCookieValue(req) {
+		session, err := s.create(req.Context(), nsxConfig)
+		if err != nil {
+			return err
+		}
+		s.session = session
+	}
+
+	s.session.apply(req)
+	return nil
+}
+
+// Close destroys the session on NSX Manager, a new session is created on the next request
+func (s *SessionAuth) Close(ctx context.Context, nsxConfig *NSXClient) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.session == nil {
+		return nil
+	}
+	session := s.session
+	s.session = nil
+
+	requestURI := fmt.Sprintf("https://%s%s", nsxConfig.Hostname, SessionDestroyEndpoint)
+	req, err := http.NewRequestWithContext(ctx, "POST", requestURI, nil)
+	if err != nil {
+		return err
+	}
+	session.apply(req)
+
+	resp, err := nsxConfig.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("error destroying session: %w", newAPIError(resp))
+	}
+
+	return nil
+}
+
+func sessionCookieValue(req *http.Request) string {
+	cookie, err := req.Cookie(SessionCookieName)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
@@ -0,0 +1,425 @@
+package gonsx
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DFW categories in the order they are evaluated, policies without a known category come last
+var dfwCategoryOrder = map[string]int{
+	"Ethernet":       0,
+	"Emergency":      1,
+	"Infrastructure": 2,
+	"Environment":    3,
+	"Application":    4,
+}
+
+// IP protocol numbers of the protocols a Flow can use
+var flowProtocolNumbers = map[string]uint8{
+	"ICMPv4": 1,
+	"TCP":    6,
+	"UDP":    17,
+	"ICMPv6": 58,
+}
+
+// Flow is a packet to evaluate against the DFW
+type Flow struct {
+	SourceIP      netip.Addr
+	DestinationIP netip.Addr
+	// TCP, UDP, ICMPv4 or ICMPv6
+	Protocol        string
+	SourcePort      int
+	DestinationPort int
+	// ICMP type and code, only used for ICMP flows
+	IcmpType *uint8
+	IcmpCode *uint8
+	// Optional display names of the source and destination VMs, matched against VM group members
+	SourceVM      string
+	DestinationVM string
+}
+
+// DfwModel is an offline snapshot of the DFW configuration a Flow is evaluated against
+type DfwModel struct {
+	// Security policies including their Rules
+	Policies []SecurityPolicy
+	// Members of every group referenced by the policies, by group path
+	Groups map[string]GroupMembers
+	// Every service referenced by the rules, including nested services, by service path
+	Services map[string]Service
+}
+
+// SimulationResult is the outcome of evaluating a Flow
+type SimulationResult struct {
+	// Whether a rule matched, when false the traffic hits no rule in the model
+	Matched  bool
+	Policy   *SecurityPolicy
+	Rule     *Rule
+	Category string
+	// Action of the matching rule, ALLOW, DROP or REJECT
+	Action string
+	// The Environment rule that jumped to the Application category, if any
+	JumpedFrom *Rule
+}
+
+// BuildDfwModel fetches the group members and services referenced by the policies, and returns
+// a model to simulate flows against. The lookups are cached in the expander.
+func (e *RuleExpander) BuildDfwModel(ctx context.Context, policies []SecurityPolicy) (*DfwModel, error) {
+	model := &DfwModel{
+		Policies: policies,
+		Groups:   map[string]GroupMembers{},
+		Services: map[string]Service{},
+	}
+
+	for _, policy := range policies {
+		_, err := e.ExpandSecurityPolicy(ctx, policy)
+		if err != nil {
+			return nil, err
+		}
+
+		// the scope of the policy and its rules limits where they are enforced
+		scopes := append([]string{}, policy.Scope...)
+		for _, rule := range policy.Rules {
+			scopes = append(scopes, rule.Scope...)
+		}
+
+		for _, path := range scopes {
+			if strings.HasPrefix(path, "/") {
+				_, err = e.groupMembers(ctx, path)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for path, members := range e.groups {
+		model.Groups[path] = *members
+	}
+	for path, service := range e.services {
+		model.Services[path] = *service
+	}
+
+	return model, nil
+}
+
+// orderedPolicies returns the policies in DFW evaluation order
+func (m *DfwModel) orderedPolicies() []SecurityPolicy {
+	policies := make([]SecurityPolicy, len(m.Policies))
+	copy(policies, m.Policies)
+
+	sort.SliceStable(policies, func(i, j int) bool {
+		ci, cj := categoryOrder(policies[i].Category), categoryOrder(policies[j].Category)
+		if ci != cj {
+			return ci < cj
+		}
+		return sequenceNumber(policies[i].SequenceNumber) < sequenceNumber(policies[j].SequenceNumber)
+	})
+
+	return policies
+}
+
+func categoryOrder(category *string) int {
+	if category != nil {
+		if order, ok := dfwCategoryOrder[*category]; ok {
+			return order
+		}
+	}
+	return len(dfwCategoryOrder)
+}
+
+func sequenceNumber(sequenceNumber *int32) int32 {
+	if sequenceNumber == nil {
+		return 0
+	}
+	return *sequenceNumber
+}
+
+func orderedRules(rules []Rule) []Rule {
+	ordered := make([]Rule, len(rules))
+	copy(ordered, rules)
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return sequenceNumber(ordered[i].SequenceNumber) < sequenceNumber(ordered[j].SequenceNumber)
+	})
+
+	return ordered
+}
+
+// Simulate evaluates the flow against the policies in DFW order: by category, then policy and
+// rule SequenceNumber. An allowing Ethernet rule skips the remaining Ethernet policies and
+// passes the flow on to the layer 3 categories, JUMP_TO_APPLICATION skips the rest of the
+// Environment category.
+func (m *DfwModel) Simulate(flow Flow) (*SimulationResult, error) {
+	var jumpedFrom *Rule
+	layer2Passed := false
+
+	for _, policy := range m.orderedPolicies() {
+		policy := policy
+		category := ""
+		if policy.Category != nil {
+			category = *policy.Category
+		}
+
+		if jumpedFrom != nil && categoryOrder(policy.Category) < dfwCategoryOrder["Application"] {
+			continue
+		}
+		if layer2Passed && categoryOrder(policy.Category) == dfwCategoryOrder["Ethernet"] {
+			continue
+		}
+
+		inScope, err := m.inScope(flow, policy.Scope)
+		if err != nil {
+			return nil, err
+		}
+		if !inScope {
+			continue
+		}
+
+		for _, rule := range orderedRules(policy.Rules) {
+			rule := rule
+			if rule.Disabled != nil && *rule.Disabled {
+				continue
+			}
+
+			matched, err := m.matchRule(flow, rule)
+			if err != nil {
+				return nil, fmt.Errorf("error evaluating rule %s: %w", ruleName(rule), err)
+			}
+			if !matched {
+				continue
+			}
+
+			action := ""
+			if rule.Action != nil {
+				action = *rule.Action
+			}
+
+			// layer 2 allow hands the packet to the layer 3 firewall
+			if category == "Ethernet" && action == "ALLOW" {
+				layer2Passed = true
+				break
+			}
+
+			if action == "JUMP_TO_APPLICATION" {
+				jumpedFrom = &rule
+				break
+			}
+
+			return &SimulationResult{
+				Matched:    true,
+				Policy:     &policy,
+				Rule:       &rule,
+				Category:   category,
+				Action:     action,
+				JumpedFrom: jumpedFrom,
+			}, nil
+		}
+	}
+
+	return &SimulationResult{JumpedFrom: jumpedFrom}, nil
+}
+
+// inScope reports whether the flow is enforced by a policy or rule with the given scope
+func (m *DfwModel) inScope(flow Flow, scope []string) (bool, error) {
+	if len(scope) == 0 {
+		return true, nil
+	}
+
+	for _, path := range scope {
+		if strings.EqualFold(path, "ANY") {
+			return true, nil
+		}
+
+		members, err := m.groupMembers(path)
+		if err != nil {
+			return false, err
+		}
+		endpoints := &EffectiveEndpoints{VirtualMachines: members.VirtualMachines, IPAddresses: members.IPAddresses}
+
+		if endpointsMatch(endpoints, flow.SourceIP, flow.SourceVM) || endpointsMatch(endpoints, flow.DestinationIP, flow.DestinationVM) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (m *DfwModel) groupMembers(path string) (*GroupMembers, error) {
+	members, ok := m.Groups[path]
+	if !ok {
+		return nil, fmt.Errorf("group %s is not in the model", path)
+	}
+	return &members, nil
+}
+
+func (m *DfwModel) service(path string) (*Service, error) {
+	service, ok := m.Services[path]
+	if !ok {
+		return nil, fmt.Errorf("service %s is not in the model", path)
+	}
+	return &service, nil
+}
+
+func (m *DfwModel) matchRule(flow Flow, rule Rule) (bool, error) {
+	inScope, err := m.inScope(flow, rule.Scope)
+	if err != nil || !inScope {
+		return false, err
+	}
+
+	sources, err := expandEndpoints(rule.SourceGroups, rule.SourcesExcluded, m.groupMembers)
+	if err != nil {
+		return false, err
+	}
+	if !endpointsMatch(sources, flow.SourceIP, flow.SourceVM) {
+		return false, nil
+	}
+
+	destinations, err := expandEndpoints(rule.DestinationGroups, rule.DestinationsExcluded, m.groupMembers)
+	if err != nil {
+		return false, err
+	}
+	if !endpointsMatch(destinations, flow.DestinationIP, flow.DestinationVM) {
+		return false, nil
+	}
+
+	services, err := expandServices(rule, m.service)
+	if err != nil {
+		return false, err
+	}
+
+	return servicesMatch(services, flow), nil
+}
+
+// endpointsMatch reports whether an address or VM is part of the endpoints, honoring Excluded
+func endpointsMatch(endpoints *EffectiveEndpoints, addr netip.Addr, vm string) bool {
+	matched := endpoints.Any
+
+	if !matched && vm != "" {
+		for _, member := range endpoints.VirtualMachines {
+			if member == vm {
+				matched = true
+				break
+			}
+		}
+	}
+
+	if !matched && addr.IsValid() {
+		for _, entry := range endpoints.IPAddresses {
+			if addressMatches(addr, entry) {
+				matched = true
+				break
+			}
+		}
+	}
+
+	if endpoints.Excluded {
+		return !matched
+	}
+	return matched
+}
+
+// addressMatches reports whether addr is the address, in the CIDR, or in the range entry
+func addressMatches(addr netip.Addr, entry string) bool {
+	entry = strings.TrimSpace(entry)
+
+	if strings.Contains(entry, "/") {
+		prefix, err := netip.ParsePrefix(entry)
+		return err == nil && prefix.Contains(addr)
+	}
+
+	if from, to, found := strings.Cut(entry, "-"); found {
+		start, err := netip.ParseAddr(strings.TrimSpace(from))
+		if err != nil {
+			return false
+		}
+		end, err := netip.ParseAddr(strings.TrimSpace(to))
+		if err != nil {
+			return false
+		}
+		return start.Compare(addr) <= 0 && addr.Compare(end) <= 0
+	}
+
+	member, err := netip.ParseAddr(entry)
+	return err == nil && member == addr
+}
+
+func servicesMatch(services *EffectiveServices, flow Flow) bool {
+	if services.Any {
+		return true
+	}
+
+	for _, entry := range services.L4PortSets {
+		if strings.EqualFold(entry.L4Protocol, flow.Protocol) &&
+			portsMatch(entry.DestinationPorts, flow.DestinationPort) &&
+			portsMatch(entry.SourcePorts, flow.SourcePort) {
+			return true
+		}
+	}
+
+	for _, entry := range services.Icmp {
+		if strings.EqualFold(entry.Protocol, flow.Protocol) &&
+			(entry.IcmpType == nil || (flow.IcmpType != nil && *entry.IcmpType == *flow.IcmpType)) &&
+			(entry.IcmpCode == nil || (flow.IcmpCode != nil && *entry.IcmpCode == *flow.IcmpCode)) {
+			return true
+		}
+	}
+
+	for _, other := range services.Other {
+		switch entry := other.(type) {
+		case *IpProtocolServiceEntry:
+			if protocolNumber, ok := flowProtocolNumbers[flow.Protocol]; ok && protocolNumber == entry.ProtocolNumber {
+				return true
+			}
+		case *AlgServiceEntry:
+			if strings.EqualFold(algProtocol(entry.Alg), flow.Protocol) &&
+				portsMatch(entry.DestinationPorts, flow.DestinationPort) &&
+				portsMatch(entry.SourcePorts, flow.SourcePort) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// algProtocol returns the layer 4 protocol an ALG runs on
+func algProtocol(alg string) string {
+	if strings.Contains(alg, "UDP") || alg == "TFTP" {
+		return "UDP"
+	}
+	return "TCP"
+}
+
+// portsMatch reports whether port is in the list of ports and port ranges, an empty list matches any port
+func portsMatch(ports []string, port int) bool {
+	if len(ports) == 0 {
+		return true
+	}
+
+	for _, entry := range ports {
+		from, to, found := strings.Cut(entry, "-")
+		start, err := strconv.Atoi(strings.TrimSpace(from))
+		if err != nil {
+			continue
+		}
+		end := start
+		if found {
+			end, err = strconv.Atoi(strings.TrimSpace(to))
+			if err != nil {
+				continue
+			}
+		}
+		if start <= port && port <= end {
+			return true
+		}
+	}
+
+	return false
+}
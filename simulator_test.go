@@ -0,0 +1,166 @@
+package gonsx
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func testRule(id, action string, sequence int32) Rule {
+	rule := Rule{}
+	rule.Id = stringPointer(id)
+	rule.Action = stringPointer(action)
+	rule.SequenceNumber = &sequence
+	return rule
+}
+
+func testPolicy(id, category string, sequence int32, rules ...Rule) SecurityPolicy {
+	policy := SecurityPolicy{}
+	policy.Id = stringPointer(id)
+	policy.Category = stringPointer(category)
+	policy.SequenceNumber = &sequence
+	policy.Rules = rules
+	return policy
+}
+
+func TestSimulate(t *testing.T) {
+	webRule := testRule("web-only", "ALLOW", 1)
+	webRule.DestinationGroups = []string{"/infra/domains/default/groups/web"}
+
+	groups := map[string]GroupMembers{
+		"/infra/domains/default/groups/web": {IPAddresses: []string{"10.0.1.0/24"}},
+	}
+
+	flow := Flow{
+		SourceIP:        netip.MustParseAddr("10.0.0.5"),
+		DestinationIP:   netip.MustParseAddr("10.0.2.7"),
+		Protocol:        "TCP",
+		SourcePort:      40000,
+		DestinationPort: 443,
+	}
+
+	tests := []struct {
+		name       string
+		policies   []SecurityPolicy
+		matched    bool
+		rule       string
+		action     string
+		jumpedFrom string
+	}{
+		{
+			name: "category precedence over sequence number",
+			policies: []SecurityPolicy{
+				testPolicy("app", "Application", 1, testRule("app-allow", "ALLOW", 1)),
+				testPolicy("emergency", "Emergency", 100, testRule("emergency-drop", "DROP", 1)),
+			},
+			matched: true,
+			rule:    "emergency-drop",
+			action:  "DROP",
+		},
+		{
+			name: "sequence number within a category",
+			policies: []SecurityPolicy{
+				testPolicy("second", "Application", 20, testRule("second-allow", "ALLOW", 1)),
+				testPolicy("first", "Application", 10, testRule("first-reject", "REJECT", 1)),
+			},
+			matched: true,
+			rule:    "first-reject",
+			action:  "REJECT",
+		},
+		{
+			name: "unknown category comes last",
+			policies: []SecurityPolicy{
+				testPolicy("other", "Custom", 1, testRule("other-drop", "DROP", 1)),
+				testPolicy("app", "Application", 100, testRule("app-allow", "ALLOW", 1)),
+			},
+			matched: true,
+			rule:    "app-allow",
+			action:  "ALLOW",
+		},
+		{
+			name: "ethernet allow skips the remaining ethernet policies",
+			policies: []SecurityPolicy{
+				testPolicy("p1", "Ethernet", 1, testRule("l2allow", "ALLOW", 1)),
+				testPolicy("p2", "Ethernet", 10, testRule("l2drop", "DROP", 1)),
+				testPolicy("p3", "Application", 1, testRule("l3allow", "ALLOW", 1)),
+			},
+			matched: true,
+			rule:    "l3allow",
+			action:  "ALLOW",
+		},
+		{
+			name: "ethernet drop",
+			policies: []SecurityPolicy{
+				testPolicy("p1", "Ethernet", 1, testRule("l2drop", "DROP", 1)),
+				testPolicy("p2", "Application", 1, testRule("l3allow", "ALLOW", 1)),
+			},
+			matched: true,
+			rule:    "l2drop",
+			action:  "DROP",
+		},
+		{
+			name: "jump to application skips the rest of environment",
+			policies: []SecurityPolicy{
+				testPolicy("env1", "Environment", 1, testRule("jump", "JUMP_TO_APPLICATION", 1), testRule("env1-drop", "DROP", 2)),
+				testPolicy("env2", "Environment", 2, testRule("env2-drop", "DROP", 1)),
+				testPolicy("app", "Application", 1, testRule("app-allow", "ALLOW", 1)),
+			},
+			matched:    true,
+			rule:       "app-allow",
+			action:     "ALLOW",
+			jumpedFrom: "jump",
+		},
+		{
+			name: "jump to application without a matching application rule",
+			policies: []SecurityPolicy{
+				testPolicy("env", "Environment", 1, testRule("jump", "JUMP_TO_APPLICATION", 1)),
+			},
+			matched:    false,
+			jumpedFrom: "jump",
+		},
+		{
+			name: "destination outside the group falls through",
+			policies: []SecurityPolicy{
+				testPolicy("app", "Application", 1, webRule, testRule("default-drop", "DROP", 2)),
+			},
+			matched: true,
+			rule:    "default-drop",
+			action:  "DROP",
+		},
+		{
+			name:     "no rule matches",
+			policies: []SecurityPolicy{testPolicy("app", "Application", 1, webRule)},
+			matched:  false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			model := &DfwModel{Policies: test.policies, Groups: groups, Services: map[string]Service{}}
+
+			result, err := model.Simulate(flow)
+			if err != nil {
+				t.Fatalf("Simulate returned an error: %v", err)
+			}
+
+			if result.Matched != test.matched {
+				t.Fatalf("Matched = %v, want %v", result.Matched, test.matched)
+			}
+			if test.matched {
+				if got := *result.Rule.Id; got != test.rule {
+					t.Errorf("Rule = %s, want %s", got, test.rule)
+				}
+				if result.Action != test.action {
+					t.Errorf("Action = %s, want %s", result.Action, test.action)
+				}
+			}
+
+			jumpedFrom := ""
+			if result.JumpedFrom != nil {
+				jumpedFrom = *result.JumpedFrom.Id
+			}
+			if jumpedFrom != test.jumpedFrom {
+				t.Errorf("JumpedFrom = %q, want %q", jumpedFrom, test.jumpedFrom)
+			}
+		})
+	}
+}
@@ -0,0 +1,316 @@
+package gonsx
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// NodeVersion is the version of the NSX manager
+type NodeVersion struct {
+	NodeVersion    string `json:"node_version"`
+	ProductVersion string `json:"product_version"`
+}
+
+// GetNodeVersion fetches the version of the NSX manager
+func GetNodeVersion(ctx context.Context, nsxConfig *NSXClient) (*NodeVersion, error) {
+	var version NodeVersion
+	err := sendManagerResource(ctx, nsxConfig, "GET", "/node/version", nil, nil, &version)
+	if err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+// Snapshot is a point in time copy of the DFW configuration
+type Snapshot struct {
+	Hostname   string    `json:"hostname"`
+	NSXVersion string    `json:"nsx_version"`
+	Timestamp  time.Time `json:"timestamp"`
+	Groups     []Group   `json:"groups"`
+	Services   []Service `json:"services"`
+	// Security policies including their rules
+	Policies []SecurityPolicy `json:"security_policies"`
+}
+
+// TakeSnapshot fetches all groups, services, security policies and rules from NSX
+func TakeSnapshot(ctx context.Context, nsxConfig NSXClient) (*Snapshot, error) {
+	version, err := GetNodeVersion(ctx, &nsxConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching NSX version: %w", err)
+	}
+
+	timestamp := time.Now().UTC()
+
+	objects, err := FetchObjectSet(ctx, nsxConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Snapshot{
+		Hostname:   nsxConfig.Hostname,
+		NSXVersion: version.ProductVersion,
+		Timestamp:  timestamp,
+		Groups:     objects.Groups,
+		Services:   objects.Services,
+		Policies:   objects.Policies,
+	}, nil
+}
+
+// ObjectSet returns the objects in the snapshot
+func (s *Snapshot) ObjectSet() *ObjectSet {
+	return &ObjectSet{Groups: s.Groups, Services: s.Services, Policies: s.Policies}
+}
+
+// Write writes the snapshot as a gzip compressed json archive
+func (s *Snapshot) Write(w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	gz.Name = fmt.Sprintf("%s-%s.json", s.Hostname, s.Timestamp.Format("20060102T150405Z"))
+	gz.ModTime = s.Timestamp
+
+	err := json.NewEncoder(gz).Encode(s)
+	if err != nil {
+		gz.Close()
+		return err
+	}
+	return gz.Close()
+}
+
+// ReadSnapshot reads a snapshot archive written by Snapshot.Write
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading snapshot: %w", err)
+	}
+	defer gz.Close()
+
+	var snapshot Snapshot
+	err = json.NewDecoder(gz).Decode(&snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding snapshot: %w", err)
+	}
+	return &snapshot, nil
+}
+
+// SaveSnapshot writes the snapshot archive to a file
+func SaveSnapshot(file string, snapshot *Snapshot) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+
+	err = snapshot.Write(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// LoadSnapshot reads a snapshot archive from a file
+func LoadSnapshot(file string) (*Snapshot, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadSnapshot(f)
+}
+
+// ObjectDiff is an added, removed or modified object, Changes is only set for modified objects
+type ObjectDiff struct {
+	// Group, Service or SecurityPolicy
+	Kind    string
+	Path    string
+	Changes []FieldChange
+}
+
+// RuleMove is a rule whose position within its security policy changed, relative to the
+// rules present before and after. Rules that only shift because of added or removed rules
+// are not moved.
+type RuleMove struct {
+	PolicyPath  string
+	RuleId      string
+	OldPosition int
+	NewPosition int
+}
+
+// SnapshotDiff is the difference between two versions of the DFW configuration
+type SnapshotDiff struct {
+	Added      []ObjectDiff
+	Removed    []ObjectDiff
+	Modified   []ObjectDiff
+	MovedRules []RuleMove
+}
+
+// Empty reports whether both versions are the same
+func (d *SnapshotDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0 && len(d.MovedRules) == 0
+}
+
+func (d *SnapshotDiff) String() string {
+	var sb strings.Builder
+	for _, object := range d.Added {
+		fmt.Fprintf(&sb, "added %s %s\n", object.Kind, object.Path)
+	}
+	for _, object := range d.Removed {
+		fmt.Fprintf(&sb, "removed %s %s\n", object.Kind, object.Path)
+	}
+	for _, object := range d.Modified {
+		fmt.Fprintf(&sb, "modified %s %s\n", object.Kind, object.Path)
+		for _, change := range object.Changes {
+			fmt.Fprintf(&sb, "    %s\n", change)
+		}
+	}
+	for _, move := range d.MovedRules {
+		fmt.Fprintf(&sb, "moved rule %s in %s from position %d to %d\n", move.RuleId, move.PolicyPath, move.OldPosition, move.NewPosition)
+	}
+	return sb.String()
+}
+
+// DiffSnapshots compares two snapshots, old is the baseline
+func DiffSnapshots(old, updated *Snapshot) (*SnapshotDiff, error) {
+	return DiffObjectSets(old.ObjectSet(), updated.ObjectSet())
+}
+
+// DiffSnapshotWithLive compares a snapshot with the current configuration on NSX
+func DiffSnapshotWithLive(ctx context.Context, nsxConfig NSXClient, snapshot *Snapshot) (*SnapshotDiff, error) {
+	live, err := FetchObjectSet(ctx, nsxConfig)
+	if err != nil {
+		return nil, err
+	}
+	return DiffObjectSets(snapshot.ObjectSet(), live)
+}
+
+// DiffObjectSets compares two sets of objects, old is the baseline. Objects are matched by path,
+// all fields except the server managed ones are compared.
+func DiffObjectSets(old, updated *ObjectSet) (*SnapshotDiff, error) {
+	diff := &SnapshotDiff{}
+
+	oldObjects := map[string]reconcileObject{}
+	for _, object := range old.objects() {
+		oldObjects[object.path] = object
+	}
+
+	newPaths := map[string]bool{}
+	for _, object := range updated.objects() {
+		newPaths[object.path] = true
+
+		oldObject, ok := oldObjects[object.path]
+		if !ok {
+			diff.Added = append(diff.Added, ObjectDiff{Kind: object.kind, Path: object.path})
+			continue
+		}
+
+		changes, err := compareObjects(object.object, oldObject.object, true)
+		if err != nil {
+			return nil, fmt.Errorf("error comparing %s %s: %w", object.kind, object.path, err)
+		}
+		if len(changes) > 0 {
+			diff.Modified = append(diff.Modified, ObjectDiff{Kind: object.kind, Path: object.path, Changes: changes})
+		}
+
+		if oldPolicy, ok := oldObject.object.(*SecurityPolicy); ok {
+			diff.MovedRules = append(diff.MovedRules, movedRules(object.path, oldPolicy, object.object.(*SecurityPolicy))...)
+		}
+	}
+
+	for _, object := range old.objects() {
+		if !newPaths[object.path] {
+			diff.Removed = append(diff.Removed, ObjectDiff{Kind: object.kind, Path: object.path})
+		}
+	}
+
+	for _, objects := range [][]ObjectDiff{diff.Added, diff.Removed, diff.Modified} {
+		sort.SliceStable(objects, func(i, j int) bool { return objects[i].Path < objects[j].Path })
+	}
+
+	return diff, nil
+}
+
+// movedRules returns the rules present in both versions of a policy whose position changed.
+// The longest run of rules that kept their relative order stays in place, only the rules
+// outside of it were moved.
+func movedRules(policyPath string, old, updated *SecurityPolicy) []RuleMove {
+	oldOrder := ruleOrder(old)
+	updatedOrder := ruleOrder(updated)
+
+	updatedIds := map[string]bool{}
+	for _, id := range updatedOrder {
+		updatedIds[id] = true
+	}
+
+	// positions among the rules present in both versions
+	oldPositions := map[string]int{}
+	for _, id := range oldOrder {
+		if updatedIds[id] {
+			oldPositions[id] = len(oldPositions)
+		}
+	}
+
+	var kept []string
+	var keptOldPositions []int
+	for _, id := range updatedOrder {
+		if position, ok := oldPositions[id]; ok {
+			kept = append(kept, id)
+			keptOldPositions = append(keptOldPositions, position)
+		}
+	}
+
+	inPlace := longestIncreasing(keptOldPositions)
+
+	var moves []RuleMove
+	for position, id := range kept {
+		if !inPlace[position] {
+			moves = append(moves, RuleMove{PolicyPath: policyPath, RuleId: id, OldPosition: oldPositions[id], NewPosition: position})
+		}
+	}
+
+	return moves
+}
+
+// longestIncreasing returns the indexes of a longest strictly increasing subsequence of values
+func longestIncreasing(values []int) map[int]bool {
+	// tails[k] is the index of the smallest value ending an increasing subsequence of length k+1
+	var tails []int
+	previous := make([]int, len(values))
+
+	for i, value := range values {
+		k := sort.Search(len(tails), func(k int) bool { return values[tails[k]] >= value })
+		previous[i] = -1
+		if k > 0 {
+			previous[i] = tails[k-1]
+		}
+		if k == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[k] = i
+		}
+	}
+
+	indexes := map[int]bool{}
+	if len(tails) > 0 {
+		for i := tails[len(tails)-1]; i >= 0; i = previous[i] {
+			indexes[i] = true
+		}
+	}
+	return indexes
+}
+
+// ruleOrder returns the rule ids of a policy in evaluation order
+func ruleOrder(policy *SecurityPolicy) []string {
+	ids := make([]string, 0, len(policy.Rules))
+	for _, rule := range orderedRules(policy.Rules) {
+		if rule.Id != nil {
+			ids = append(ids, *rule.Id)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,151 @@
+package gonsx
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testRules creates ALLOW rules in the order of ids
+func testRules(ids ...string) []Rule {
+	rules := make([]Rule, 0, len(ids))
+	for i, id := range ids {
+		rules = append(rules, testRule(id, "ALLOW", int32(i+1)*10))
+	}
+	return rules
+}
+
+func TestDiffObjectSets(t *testing.T) {
+	policyPath := SecurityPolicyPath(DefaultDomainId, "web")
+	webPath := GroupPath(DefaultDomainId, "web")
+	dbPath := GroupPath(DefaultDomainId, "db")
+
+	policySet := func(ids ...string) *ObjectSet {
+		return &ObjectSet{Policies: []SecurityPolicy{testPolicy("web", "Application", 1, testRules(ids...)...)}}
+	}
+	move := func(id string, oldPosition, newPosition int) RuleMove {
+		return RuleMove{PolicyPath: policyPath, RuleId: id, OldPosition: oldPosition, NewPosition: newPosition}
+	}
+
+	tests := []struct {
+		name     string
+		old      *ObjectSet
+		updated  *ObjectSet
+		added    []string
+		removed  []string
+		modified []string
+		moves    []RuleMove
+	}{
+		{
+			name:    "add",
+			old:     &ObjectSet{Groups: []Group{testGroup("web", "web")}},
+			updated: &ObjectSet{Groups: []Group{testGroup("web", "web"), testGroup("db", "db")}},
+			added:   []string{dbPath},
+		},
+		{
+			name:    "remove",
+			old:     &ObjectSet{Groups: []Group{testGroup("web", "web"), testGroup("db", "db")}},
+			updated: &ObjectSet{Groups: []Group{testGroup("web", "web")}},
+			removed: []string{dbPath},
+		},
+		{
+			name:     "modify",
+			old:      &ObjectSet{Groups: []Group{testGroup("web", "web")}},
+			updated:  &ObjectSet{Groups: []Group{testGroup("web", "web servers")}},
+			modified: []string{webPath},
+		},
+		{
+			name:    "unchanged",
+			old:     policySet("a", "b", "c"),
+			updated: policySet("a", "b", "c"),
+		},
+		{
+			name:     "single move to the end",
+			old:      policySet("a", "b", "c", "d"),
+			updated:  policySet("b", "c", "d", "a"),
+			modified: []string{policyPath},
+			moves:    []RuleMove{move("a", 0, 3)},
+		},
+		{
+			name:     "single move to the front",
+			old:      policySet("a", "b", "c", "d"),
+			updated:  policySet("d", "a", "b", "c"),
+			modified: []string{policyPath},
+			moves:    []RuleMove{move("d", 3, 0)},
+		},
+		{
+			name:     "shift by an added rule",
+			old:      policySet("a", "b", "c"),
+			updated:  policySet("new", "a", "b", "c"),
+			modified: []string{policyPath},
+		},
+		{
+			name:     "shift by a removed rule",
+			old:      policySet("a", "b", "c"),
+			updated:  policySet("b", "c"),
+			modified: []string{policyPath},
+		},
+	}
+
+	paths := func(objects []ObjectDiff) []string {
+		var paths []string
+		for _, object := range objects {
+			paths = append(paths, object.Path)
+		}
+		return paths
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			diff, err := DiffObjectSets(test.old, test.updated)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := paths(diff.Added); !reflect.DeepEqual(got, test.added) {
+				t.Errorf("Added = %q, want %q", got, test.added)
+			}
+			if got := paths(diff.Removed); !reflect.DeepEqual(got, test.removed) {
+				t.Errorf("Removed = %q, want %q", got, test.removed)
+			}
+			if got := paths(diff.Modified); !reflect.DeepEqual(got, test.modified) {
+				t.Errorf("Modified = %q, want %q", got, test.modified)
+			}
+			if !reflect.DeepEqual(diff.MovedRules, test.moves) {
+				t.Errorf("MovedRules = %+v, want %+v", diff.MovedRules, test.moves)
+			}
+			if empty := test.added == nil && test.removed == nil && test.modified == nil && test.moves == nil; diff.Empty() != empty {
+				t.Errorf("Empty() = %v, want %v", diff.Empty(), empty)
+			}
+		})
+	}
+}
+
+func TestLongestIncreasing(t *testing.T) {
+	tests := []struct {
+		values []int
+		want   int
+	}{
+		{nil, 0},
+		{[]int{0, 1, 2}, 3},
+		{[]int{1, 2, 3, 0}, 3},
+		{[]int{3, 0, 1, 2}, 3},
+		{[]int{1, 0}, 1},
+		{[]int{2, 0, 3, 1, 4}, 3},
+	}
+
+	for _, test := range tests {
+		indexes := longestIncreasing(test.values)
+		if len(indexes) != test.want {
+			t.Errorf("longestIncreasing(%v) has length %d, want %d", test.values, len(indexes), test.want)
+		}
+		last := -1
+		for i := range test.values {
+			if indexes[i] {
+				if test.values[i] <= last {
+					t.Errorf("longestIncreasing(%v) = %v is not increasing", test.values, indexes)
+				}
+				last = test.values[i]
+			}
+		}
+	}
+}
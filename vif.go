@@ -0,0 +1,158 @@
+package gonsx
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+)
+
+const (
+	VirtualNetworkInterfacesEndpoint = "/fabric/vifs"
+)
+
+type VirtualNetworkInterface struct {
+	BaseNsxPolicyApiResource
+	// Device key of the interface on the VM
+	DeviceKey *string `json:"device_key,omitempty"`
+	// Device name of the interface on the VM
+	DeviceName *string `json:"device_name,omitempty"`
+	// External id of the interface
+	ExternalId string `json:"external_id"`
+	// Id of the host the VM of the interface runs on
+	HostId *string `json:"host_id,omitempty"`
+	// IP addresses of the interface, per source they were discovered from
+	IpAddressInfo []IpAddressInfo `json:"ip_address_info,omitempty"`
+	// Id of the logical port attachment of the interface
+	LportAttachmentId *string `json:"lport_attachment_id,omitempty"`
+	MacAddress        string  `json:"mac_address"`
+	// External id of the VM the interface belongs to
+	OwnerVmId   string  `json:"owner_vm_id"`
+	OwnerVmType *string `json:"owner_vm_type,omitempty"`
+	// Id of the VM on its host
+	VmLocalIdOnHost *string `json:"vm_local_id_on_host,omitempty"`
+	// Timestamp of the last sync with the host
+	LastSyncTime *int64 `json:"_last_sync_time,omitempty"`
+}
+
+type IpAddressInfo struct {
+	IpAddresses []string `json:"ip_addresses,omitempty"`
+	// Where the IP addresses were discovered, e.g. VM_TOOLS
+	Source *string `json:"source,omitempty"`
+}
+
+// IPAddresses returns the IP addresses of the interface from all sources, without duplicates
+func (vif VirtualNetworkInterface) IPAddresses() []string {
+	var ipAddresses []string
+	for _, info := range vif.IpAddressInfo {
+		ipAddresses = appendUnique(ipAddresses, info.IpAddresses...)
+	}
+	return ipAddresses
+}
+
+// VifFilter limits the interfaces returned by ListVirtualNetworkInterfaces, empty fields don't filter
+type VifFilter struct {
+	// External id of the VM
+	OwnerVmId         string
+	HostId            string
+	LportAttachmentId string
+}
+
+func (f VifFilter) values() url.Values {
+	query := url.Values{}
+	if f.OwnerVmId != "" {
+		query.Set("owner_vm_id", f.OwnerVmId)
+	}
+	if f.HostId != "" {
+		query.Set("host_id", f.HostId)
+	}
+	if f.LportAttachmentId != "" {
+		query.Set("lport_attachment_id", f.LportAttachmentId)
+	}
+	return query
+}
+
+// ListVirtualNetworkInterfaces fetches the VM network interfaces matching the filter
+func ListVirtualNetworkInterfaces(ctx context.Context, nsxConfig *NSXClient, filter VifFilter) ([]VirtualNetworkInterface, error) {
+	return listManagerResources[VirtualNetworkInterface](ctx, nsxConfig, VirtualNetworkInterfacesEndpoint, filter.values())
+}
+
+// GetInterfaces fetches the network interfaces of the VM
+func (vm VirtualMachine) GetInterfaces(ctx context.Context, nsxConfig *NSXClient) ([]VirtualNetworkInterface, error) {
+	if vm.ExternalId == "" {
+		return nil, fmt.Errorf("VM has no external id")
+	}
+	return ListVirtualNetworkInterfaces(ctx, nsxConfig, VifFilter{OwnerVmId: vm.ExternalId})
+}
+
+// VmInterfaces is a VM with its network interfaces and their IP addresses
+type VmInterfaces struct {
+	VirtualMachine VirtualMachine
+	Interfaces     []VirtualNetworkInterface
+	// IP addresses of all interfaces, without duplicates
+	IPAddresses []string
+}
+
+// JoinVmInterfaces pairs every VM with the interfaces it owns, in the order of vms. Interfaces
+// of VMs that are not in vms are left out.
+func JoinVmInterfaces(vms []VirtualMachine, vifs []VirtualNetworkInterface) []VmInterfaces {
+	vifsByOwner := map[string][]VirtualNetworkInterface{}
+	for _, vif := range vifs {
+		vifsByOwner[vif.OwnerVmId] = append(vifsByOwner[vif.OwnerVmId], vif)
+	}
+
+	joined := make([]VmInterfaces, 0, len(vms))
+	for _, vm := range vms {
+		interfaces := vifsByOwner[vm.ExternalId]
+		sort.SliceStable(interfaces, func(i, j int) bool {
+			return interfaces[i].MacAddress < interfaces[j].MacAddress
+		})
+
+		vmInterfaces := VmInterfaces{VirtualMachine: vm, Interfaces: interfaces}
+		for _, vif := range interfaces {
+			vmInterfaces.IPAddresses = appendUnique(vmInterfaces.IPAddresses, vif.IPAddresses()...)
+		}
+		joined = append(joined, vmInterfaces)
+	}
+
+	return joined
+}
+
+// GetVmInterfaces fetches all VMs and network interfaces and joins them
+func GetVmInterfaces(ctx context.Context, nsxConfig *NSXClient) ([]VmInterfaces, error) {
+	vms, err := SearchAll[VirtualMachine](ctx, *nsxConfig, NewSearchQuery(ResourceTypeIs("VirtualMachine")))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching VMs: %w", err)
+	}
+
+	vifs, err := ListVirtualNetworkInterfaces(ctx, nsxConfig, VifFilter{})
+	if err != nil {
+		return nil, fmt.Errorf("error fetching network interfaces: %w", err)
+	}
+
+	return JoinVmInterfaces(vms, vifs), nil
+}
+
+// IndexByIPAddress maps every IP address to the VMs that have it, more than one VM means the
+// address is in use twice, e.g. on isolated networks
+func IndexByIPAddress(joined []VmInterfaces) map[string][]VirtualMachine {
+	index := map[string][]VirtualMachine{}
+	for _, vm := range joined {
+		for _, ip := range vm.IPAddresses {
+			index[ip] = append(index[ip], vm.VirtualMachine)
+		}
+	}
+	return index
+}
+
+// Member converts the VM into an inventory member, including the IP and MAC addresses of its interfaces
+func (v VmInterfaces) Member() InventoryMember {
+	member := VirtualMachineMember(v.VirtualMachine)
+	member.IPAddresses = v.IPAddresses
+	for _, vif := range v.Interfaces {
+		if vif.MacAddress != "" {
+			member.MACAddresses = appendUnique(member.MACAddresses, vif.MacAddress)
+		}
+	}
+	return member
+}
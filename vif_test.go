@@ -0,0 +1,96 @@
+package gonsx
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testVif(owner, mac string, ipAddresses ...[]string) VirtualNetworkInterface {
+	vif := VirtualNetworkInterface{OwnerVmId: owner, MacAddress: mac}
+	for _, addresses := range ipAddresses {
+		vif.IpAddressInfo = append(vif.IpAddressInfo, IpAddressInfo{IpAddresses: addresses})
+	}
+	return vif
+}
+
+func TestJoinVmInterfaces(t *testing.T) {
+	vms := []VirtualMachine{testVm("vm-1", "web-1"), testVm("vm-2", "web-2"), testVm("vm-3", "db")}
+
+	tests := []struct {
+		name string
+		vifs []VirtualNetworkInterface
+		// MAC addresses and IP addresses per VM, in the order of vms
+		wantMacs [][]string
+		wantIPs  [][]string
+		// VM ids per IP address
+		wantIndex map[string][]string
+	}{
+		{
+			name:      "no interfaces",
+			wantMacs:  [][]string{nil, nil, nil},
+			wantIPs:   [][]string{nil, nil, nil},
+			wantIndex: map[string][]string{},
+		},
+		{
+			name: "interfaces sorted by MAC and addresses without duplicates",
+			vifs: []VirtualNetworkInterface{
+				testVif("vm-1", "00:50:56:00:00:02", []string{"10.0.0.2"}),
+				testVif("vm-1", "00:50:56:00:00:01", []string{"10.0.0.1", "fe80::1"}, []string{"10.0.0.1"}),
+				testVif("vm-3", "00:50:56:00:00:03", []string{"10.0.1.1"}),
+			},
+			wantMacs: [][]string{{"00:50:56:00:00:01", "00:50:56:00:00:02"}, nil, {"00:50:56:00:00:03"}},
+			wantIPs:  [][]string{{"10.0.0.1", "fe80::1", "10.0.0.2"}, nil, {"10.0.1.1"}},
+			wantIndex: map[string][]string{
+				"10.0.0.1": {"vm-1"},
+				"fe80::1":  {"vm-1"},
+				"10.0.0.2": {"vm-1"},
+				"10.0.1.1": {"vm-3"},
+			},
+		},
+		{
+			name: "address shared by two VMs and interface of an unknown VM",
+			vifs: []VirtualNetworkInterface{
+				testVif("vm-1", "00:50:56:00:00:01", []string{"192.168.1.10"}),
+				testVif("vm-2", "00:50:56:00:00:02", []string{"192.168.1.10"}),
+				testVif("vm-9", "00:50:56:00:00:09", []string{"192.168.1.99"}),
+			},
+			wantMacs:  [][]string{{"00:50:56:00:00:01"}, {"00:50:56:00:00:02"}, nil},
+			wantIPs:   [][]string{{"192.168.1.10"}, {"192.168.1.10"}, nil},
+			wantIndex: map[string][]string{"192.168.1.10": {"vm-1", "vm-2"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			joined := JoinVmInterfaces(vms, test.vifs)
+
+			if len(joined) != len(vms) {
+				t.Fatalf("got %d VMs, want %d", len(joined), len(vms))
+			}
+			for i, vm := range joined {
+				if vm.VirtualMachine.ExternalId != vms[i].ExternalId {
+					t.Errorf("VM %d is %s, want %s", i, vm.VirtualMachine.ExternalId, vms[i].ExternalId)
+				}
+
+				var macs []string
+				for _, vif := range vm.Interfaces {
+					macs = append(macs, vif.MacAddress)
+				}
+				if !reflect.DeepEqual(macs, test.wantMacs[i]) {
+					t.Errorf("interfaces of %s = %q, want %q", vms[i].ExternalId, macs, test.wantMacs[i])
+				}
+				if !reflect.DeepEqual(vm.IPAddresses, test.wantIPs[i]) {
+					t.Errorf("IP addresses of %s = %q, want %q", vms[i].ExternalId, vm.IPAddresses, test.wantIPs[i])
+				}
+			}
+
+			index := map[string][]string{}
+			for ip, vms := range IndexByIPAddress(joined) {
+				index[ip] = vmIds(vms)
+			}
+			if !reflect.DeepEqual(index, test.wantIndex) {
+				t.Errorf("IndexByIPAddress = %v, want %v", index, test.wantIndex)
+			}
+		})
+	}
+}
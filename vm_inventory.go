@@ -0,0 +1,251 @@
+package gonsx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Keys of VirtualMachine.ComputeIds
+const (
+	ComputeIdBiosUuid     = "biosUuid"
+	ComputeIdInstanceUuid = "instanceUuid"
+)
+
+// ComputeId returns the value of a compute id of the VM, e.g. ComputeIdBiosUuid
+func (vm VirtualMachine) ComputeId(key string) (string, bool) {
+	for _, computeId := range vm.ComputeIds {
+		computeKey, value, found := strings.Cut(computeId, ":")
+		if found && computeKey == key {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+func findVms(ctx context.Context, nsxConfig NSXClient, clause SearchClause) ([]VirtualMachine, error) {
+	return SearchAll[VirtualMachine](ctx, nsxConfig, NewSearchQuery(ResourceTypeIs("VirtualMachine"), clause))
+}
+
+// GetVmByExternalId fetches the VM with the external id, the error wraps ErrNotFound if there is none
+func GetVmByExternalId(ctx context.Context, nsxConfig NSXClient, externalId string) (*VirtualMachine, error) {
+	vms, err := findVms(ctx, nsxConfig, FieldEquals("external_id", externalId))
+	if err != nil {
+		return nil, err
+	}
+	for _, vm := range vms {
+		if vm.ExternalId == externalId {
+			return &vm, nil
+		}
+	}
+	return nil, fmt.Errorf("VM %s: %w", externalId, ErrNotFound)
+}
+
+// FindVmsByBiosUuid fetches the VMs with a BIOS UUID, cloned VMs can share one
+func FindVmsByBiosUuid(ctx context.Context, nsxConfig NSXClient, uuid string) ([]VirtualMachine, error) {
+	return findVms(ctx, nsxConfig, FieldEquals("compute_ids", ComputeIdBiosUuid+":"+uuid))
+}
+
+// FindVmsByInstanceUuid fetches the VMs with a vCenter instance UUID
+func FindVmsByInstanceUuid(ctx context.Context, nsxConfig NSXClient, uuid string) ([]VirtualMachine, error) {
+	return findVms(ctx, nsxConfig, FieldEquals("compute_ids", ComputeIdInstanceUuid+":"+uuid))
+}
+
+// FindVmsByComputerName fetches the VMs whose guest reports the computer name
+func FindVmsByComputerName(ctx context.Context, nsxConfig NSXClient, computerName string) ([]VirtualMachine, error) {
+	return findVms(ctx, nsxConfig, FieldEquals("guest_info.computer_name", computerName))
+}
+
+// FindVmsByDisplayName fetches the VMs with a display name
+func FindVmsByDisplayName(ctx context.Context, nsxConfig NSXClient, displayName string) ([]VirtualMachine, error) {
+	return findVms(ctx, nsxConfig, FieldEquals("display_name", displayName))
+}
+
+// FindVmsByHost fetches the VMs running on a transport node
+func FindVmsByHost(ctx context.Context, nsxConfig NSXClient, hostId string) ([]VirtualMachine, error) {
+	return findVms(ctx, nsxConfig, FieldEquals("host_id", hostId))
+}
+
+// Inventory indexes a scan of all VMs for lookups without further API calls, and maps VMs to
+// the groups their tags and names make them a member of. Lookups by name and UUID are case
+// insensitive.
+type Inventory struct {
+	VirtualMachines []VirtualMachine
+	Groups          []Group
+
+	byExternalId   map[string]int
+	byBiosUuid     map[string][]int
+	byInstanceUuid map[string][]int
+	byComputerName map[string][]int
+	byDisplayName  map[string][]int
+	byHost         map[string][]int
+	byTag          map[Tag][]int
+
+	groupsOnce  sync.Once
+	vmGroups    map[string][]int
+	groupErrors error
+}
+
+// LoadInventory scans all VMs and groups once and indexes them
+func LoadInventory(ctx context.Context, nsxConfig NSXClient) (*Inventory, error) {
+	vms, err := SearchAll[VirtualMachine](ctx, nsxConfig, NewSearchQuery(ResourceTypeIs("VirtualMachine")))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching VMs: %w", err)
+	}
+
+	groups, err := SearchAll[Group](ctx, nsxConfig, NewSearchQuery(ResourceTypeIs("Group"), MarkedForDelete(false)))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching groups: %w", err)
+	}
+
+	return NewInventory(vms, groups), nil
+}
+
+// NewInventory indexes VMs and groups
+func NewInventory(vms []VirtualMachine, groups []Group) *Inventory {
+	inventory := &Inventory{
+		VirtualMachines: vms,
+		Groups:          groups,
+		byExternalId:    map[string]int{},
+		byBiosUuid:      map[string][]int{},
+		byInstanceUuid:  map[string][]int{},
+		byComputerName:  map[string][]int{},
+		byDisplayName:   map[string][]int{},
+		byHost:          map[string][]int{},
+		byTag:           map[Tag][]int{},
+	}
+
+	add := func(index map[string][]int, key string, i int) {
+		if key != "" {
+			key = strings.ToLower(key)
+			index[key] = append(index[key], i)
+		}
+	}
+
+	for i, vm := range vms {
+		inventory.byExternalId[strings.ToLower(vm.ExternalId)] = i
+
+		if uuid, ok := vm.ComputeId(ComputeIdBiosUuid); ok {
+			add(inventory.byBiosUuid, uuid, i)
+		}
+		if uuid, ok := vm.ComputeId(ComputeIdInstanceUuid); ok {
+			add(inventory.byInstanceUuid, uuid, i)
+		}
+		if vm.GuestInfo != nil && vm.GuestInfo.ComputerName != nil {
+			add(inventory.byComputerName, *vm.GuestInfo.ComputerName, i)
+		}
+		if vm.DisplayName != nil {
+			add(inventory.byDisplayName, *vm.DisplayName, i)
+		}
+		if vm.HostId != nil {
+			add(inventory.byHost, *vm.HostId, i)
+		}
+		for _, tag := range vm.Tags {
+			key := Tag{Scope: strings.ToLower(tag.Scope), Tag: strings.ToLower(tag.Tag)}
+			inventory.byTag[key] = append(inventory.byTag[key], i)
+		}
+	}
+
+	return inventory
+}
+
+func (inv *Inventory) vms(indexes []int) []VirtualMachine {
+	vms := make([]VirtualMachine, 0, len(indexes))
+	for _, i := range indexes {
+		vms = append(vms, inv.VirtualMachines[i])
+	}
+	return vms
+}
+
+// ByExternalId returns the VM with the external id
+func (inv *Inventory) ByExternalId(externalId string) (*VirtualMachine, bool) {
+	i, ok := inv.byExternalId[strings.ToLower(externalId)]
+	if !ok {
+		return nil, false
+	}
+	return &inv.VirtualMachines[i], true
+}
+
+// ByBiosUuid returns the VMs with a BIOS UUID
+func (inv *Inventory) ByBiosUuid(uuid string) []VirtualMachine {
+	return inv.vms(inv.byBiosUuid[strings.ToLower(uuid)])
+}
+
+// ByInstanceUuid returns the VMs with a vCenter instance UUID
+func (inv *Inventory) ByInstanceUuid(uuid string) []VirtualMachine {
+	return inv.vms(inv.byInstanceUuid[strings.ToLower(uuid)])
+}
+
+// ByComputerName returns the VMs whose guest reports the computer name
+func (inv *Inventory) ByComputerName(computerName string) []VirtualMachine {
+	return inv.vms(inv.byComputerName[strings.ToLower(computerName)])
+}
+
+// ByDisplayName returns the VMs with a display name
+func (inv *Inventory) ByDisplayName(displayName string) []VirtualMachine {
+	return inv.vms(inv.byDisplayName[strings.ToLower(displayName)])
+}
+
+// ByHost returns the VMs running on a transport node
+func (inv *Inventory) ByHost(hostId string) []VirtualMachine {
+	return inv.vms(inv.byHost[strings.ToLower(hostId)])
+}
+
+// ByTag returns the VMs with a tag, an empty scope only matches tags without a scope
+func (inv *Inventory) ByTag(scope, tag string) []VirtualMachine {
+	return inv.vms(inv.byTag[Tag{Scope: strings.ToLower(scope), Tag: strings.ToLower(tag)}])
+}
+
+// GroupsOf returns the groups the VM with the external id is a member of, as computed by an
+// ExpressionEvaluator from the VM names and tags. Membership is computed for all VMs on the
+// first call. Groups that can't be evaluated locally are skipped, see EvaluationErrors.
+func (inv *Inventory) GroupsOf(externalId string) ([]Group, error) {
+	inv.groupsOnce.Do(inv.evaluateGroups)
+
+	vm, ok := inv.ByExternalId(externalId)
+	if !ok {
+		return nil, fmt.Errorf("VM %s: %w", externalId, ErrNotFound)
+	}
+
+	indexes := inv.vmGroups[VirtualMachineMember(*vm).Path]
+	groups := make([]Group, 0, len(indexes))
+	for _, i := range indexes {
+		groups = append(groups, inv.Groups[i])
+	}
+
+	return groups, nil
+}
+
+// EvaluationErrors returns the errors of the groups GroupsOf skipped because they can't be
+// evaluated locally, nil if all groups were evaluated
+func (inv *Inventory) EvaluationErrors() error {
+	inv.groupsOnce.Do(inv.evaluateGroups)
+	return inv.groupErrors
+}
+
+func (inv *Inventory) evaluateGroups() {
+	members := make([]InventoryMember, 0, len(inv.VirtualMachines))
+	for _, vm := range inv.VirtualMachines {
+		members = append(members, VirtualMachineMember(vm))
+	}
+
+	evaluator := NewExpressionEvaluator(members, inv.Groups)
+	inv.vmGroups = map[string][]int{}
+	var errs []error
+
+	for i, group := range inv.Groups {
+		membership, err := evaluator.Evaluate(group)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for path := range membership.Members {
+			inv.vmGroups[path] = append(inv.vmGroups[path], i)
+		}
+	}
+
+	inv.groupErrors = errors.Join(errs...)
+}
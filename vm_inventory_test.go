@@ -0,0 +1,110 @@
+package gonsx
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func testVm(externalId, displayName string, tags ...Tag) VirtualMachine {
+	vm := VirtualMachine{ExternalId: externalId}
+	vm.Tags = tags
+	vm.DisplayName = stringPointer(displayName)
+	return vm
+}
+
+func vmIds(vms []VirtualMachine) []string {
+	var ids []string
+	for _, vm := range vms {
+		ids = append(ids, vm.ExternalId)
+	}
+	return ids
+}
+
+func TestInventoryLookups(t *testing.T) {
+	web1 := testVm("vm-1", "web-1", Tag{Scope: "app", Tag: "web"})
+	web1.ComputeIds = []string{"biosUuid:4212-AAAA", "instanceUuid:5012-BBBB"}
+	web1.GuestInfo = &GuestInfo{ComputerName: stringPointer("WEB-1.example.com")}
+	web1.HostId = stringPointer("host-1")
+
+	// a clone shares the BIOS UUID
+	web2 := testVm("vm-2", "web-2", Tag{Scope: "app", Tag: "web"})
+	web2.ComputeIds = []string{"biosUuid:4212-aaaa"}
+	web2.HostId = stringPointer("host-1")
+
+	db := testVm("vm-3", "db", Tag{Tag: "db"})
+
+	inventory := NewInventory([]VirtualMachine{web1, web2, db}, nil)
+
+	if vm, ok := inventory.ByExternalId("VM-1"); !ok || vm.ExternalId != "vm-1" {
+		t.Errorf("ByExternalId(VM-1) = %v, %v, want vm-1", vm, ok)
+	}
+	if _, ok := inventory.ByExternalId("vm-9"); ok {
+		t.Error("ByExternalId(vm-9) found a VM")
+	}
+
+	tests := []struct {
+		name string
+		vms  []VirtualMachine
+		want []string
+	}{
+		{"ByBiosUuid", inventory.ByBiosUuid("4212-aaaa"), []string{"vm-1", "vm-2"}},
+		{"ByInstanceUuid", inventory.ByInstanceUuid("5012-bbbb"), []string{"vm-1"}},
+		{"ByComputerName", inventory.ByComputerName("web-1.EXAMPLE.com"), []string{"vm-1"}},
+		{"ByDisplayName", inventory.ByDisplayName("DB"), []string{"vm-3"}},
+		{"ByHost", inventory.ByHost("host-1"), []string{"vm-1", "vm-2"}},
+		{"ByTag", inventory.ByTag("app", "web"), []string{"vm-1", "vm-2"}},
+		{"ByTag without scope", inventory.ByTag("", "db"), []string{"vm-3"}},
+		{"ByTag scope must match", inventory.ByTag("app", "db"), nil},
+	}
+
+	for _, test := range tests {
+		if got := vmIds(test.vms); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestInventoryGroupsOf(t *testing.T) {
+	web := testGroup("web", "web")
+	web.Path = stringPointer(GroupPath(DefaultDomainId, "web"))
+	web.Expression = []DynamicExpressionWrapper{{Expression: TagEqualsCondition(MemberTypeVirtualMachine, "app", "web")}}
+
+	all := testGroup("all", "all", *web.Path)
+	all.Path = stringPointer(GroupPath(DefaultDomainId, "all"))
+
+	// the evaluator doesn't know the key, so the group is skipped
+	broken := testGroup("broken", "broken")
+	broken.Path = stringPointer(GroupPath(DefaultDomainId, "broken"))
+	broken.Expression = []DynamicExpressionWrapper{{Expression: ConditionExpression(MemberTypeVirtualMachine, "Unknown", "EQUALS", "x")}}
+
+	inventory := NewInventory(
+		[]VirtualMachine{testVm("vm-1", "web-1", Tag{Scope: "app", Tag: "web"}), testVm("vm-2", "db")},
+		[]Group{web, all, broken},
+	)
+
+	groups, err := inventory.GroupsOf("vm-1")
+	if err != nil {
+		t.Fatalf("GroupsOf(vm-1) returned %v, want no error for a VM whose groups evaluated", err)
+	}
+	var ids []string
+	for _, group := range groups {
+		ids = append(ids, *group.Id)
+	}
+	if want := []string{"web", "all"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("GroupsOf(vm-1) = %q, want %q", ids, want)
+	}
+
+	groups, err = inventory.GroupsOf("vm-2")
+	if err != nil || len(groups) != 0 {
+		t.Errorf("GroupsOf(vm-2) = %v, %v, want no groups", groups, err)
+	}
+
+	if _, err := inventory.GroupsOf("vm-9"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GroupsOf(vm-9) error = %v, want ErrNotFound", err)
+	}
+
+	if err := inventory.EvaluationErrors(); err == nil {
+		t.Error("EvaluationErrors() = nil, want the error of the broken group")
+	}
+}
@@ -0,0 +1,208 @@
+package gonsx
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+)
+
+// TagOperation is how a tag change is applied to a VM
+type TagOperation string
+
+const (
+	// Adds the tags, keeping the existing tags
+	TagAdd TagOperation = "add_tags"
+	// Removes the tags, keeping the other tags
+	TagRemove TagOperation = "remove_tags"
+	// Replaces all tags of the VM with the tags
+	TagReplace TagOperation = "update_tags"
+)
+
+const (
+	VirtualMachinesEndpoint = "/fabric/virtual-machines"
+	// Default number of VMs tagged in parallel by BulkTagVms
+	DefaultTagConcurrency = 8
+)
+
+type vmTagsRequest struct {
+	ExternalId string `json:"external_id"`
+	Tags       []Tag  `json:"tags"`
+}
+
+// ChangeVmTags applies a tag operation to the VM with the external id
+func ChangeVmTags(ctx context.Context, nsxConfig *NSXClient, operation TagOperation, externalId string, tags ...Tag) error {
+	switch operation {
+	case TagAdd, TagRemove, TagReplace:
+	default:
+		return fmt.Errorf("unknown tag operation %q", operation)
+	}
+
+	// update_tags with no tags removes all tags, so the list must not be encoded as null
+	if tags == nil {
+		tags = []Tag{}
+	}
+
+	query := url.Values{"action": []string{string(operation)}}
+	return sendManagerResource(ctx, nsxConfig, "POST", VirtualMachinesEndpoint, query, vmTagsRequest{ExternalId: externalId, Tags: tags}, nil)
+}
+
+// AddVmTags adds tags to a VM
+func AddVmTags(ctx context.Context, nsxConfig *NSXClient, externalId string, tags ...Tag) error {
+	return ChangeVmTags(ctx, nsxConfig, TagAdd, externalId, tags...)
+}
+
+// RemoveVmTags removes tags from a VM
+func RemoveVmTags(ctx context.Context, nsxConfig *NSXClient, externalId string, tags ...Tag) error {
+	return ChangeVmTags(ctx, nsxConfig, TagRemove, externalId, tags...)
+}
+
+// ReplaceVmTags replaces all tags of a VM
+func ReplaceVmTags(ctx context.Context, nsxConfig *NSXClient, externalId string, tags ...Tag) error {
+	return ChangeVmTags(ctx, nsxConfig, TagReplace, externalId, tags...)
+}
+
+// VmSelector selects the VMs of a bulk tag change, VMs matching any of the criteria are selected
+type VmSelector struct {
+	ExternalIds  []string
+	DisplayNames []string
+	// Additional search query, it is limited to VirtualMachine results
+	Query *SearchQuery
+}
+
+// VmTagResult is the outcome of a tag change for a single VM
+type VmTagResult struct {
+	ExternalId  string
+	DisplayName string
+	// nil when the tags were changed
+	Err error
+}
+
+// BulkTagOptions controls a bulk tag change
+type BulkTagOptions struct {
+	// Number of VMs tagged in parallel, defaults to DefaultTagConcurrency
+	Concurrency int
+}
+
+// BulkTagVms applies a tag operation to all selected VMs. A failure for one VM doesn't stop
+// the others, the result of every VM is returned in the order of selector.ExternalIds, then
+// selector.DisplayNames, then the other VMs matching selector.Query in search order. Requested
+// external ids that don't exist get a result wrapping ErrNotFound, in their place.
+func BulkTagVms(ctx context.Context, nsxConfig NSXClient, selector VmSelector, operation TagOperation, tags []Tag, opts BulkTagOptions) ([]VmTagResult, error) {
+	results, err := selectVms(ctx, nsxConfig, selector)
+	if err != nil {
+		return nil, err
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultTagConcurrency
+	}
+	if concurrency > len(results) {
+		concurrency = len(results)
+	}
+
+	indexChannel := make(chan int, len(results))
+	for i, result := range results {
+		if result.Err == nil {
+			indexChannel <- i
+		}
+	}
+	close(indexChannel)
+
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexChannel {
+				if ctx.Err() != nil {
+					results[index].Err = ctx.Err()
+					continue
+				}
+				results[index].Err = ChangeVmTags(ctx, &nsxConfig, operation, results[index].ExternalId, tags...)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return results, nil
+}
+
+// selectVms resolves a selector into one result per VM in selector order, with an error for
+// unknown external ids
+func selectVms(ctx context.Context, nsxConfig NSXClient, selector VmSelector) ([]VmTagResult, error) {
+	var criteria []SearchClause
+	if len(selector.ExternalIds) > 0 {
+		clauses := make([]SearchClause, 0, len(selector.ExternalIds))
+		for _, externalId := range selector.ExternalIds {
+			clauses = append(clauses, FieldEquals("external_id", externalId))
+		}
+		criteria = append(criteria, SearchOr(clauses...))
+	}
+	if len(selector.DisplayNames) > 0 {
+		clauses := make([]SearchClause, 0, len(selector.DisplayNames))
+		for _, displayName := range selector.DisplayNames {
+			clauses = append(clauses, FieldEquals("display_name", displayName))
+		}
+		criteria = append(criteria, SearchOr(clauses...))
+	}
+	if selector.Query != nil && selector.Query.Query != "" {
+		criteria = append(criteria, selector.Query.Query)
+	}
+	if len(criteria) == 0 {
+		return nil, fmt.Errorf("the VM selector is empty")
+	}
+
+	query := NewSearchQuery(ResourceTypeIs("VirtualMachine"), SearchOr(criteria...))
+	if selector.Query != nil {
+		query.PageSize = selector.Query.PageSize
+	}
+
+	vms, err := SearchAll[VirtualMachine](ctx, nsxConfig, query)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting VMs: %w", err)
+	}
+
+	// results follow the selector: external ids, then display names, then other query matches
+	vmsByExternalId := map[string]VirtualMachine{}
+	for _, vm := range vms {
+		vmsByExternalId[vm.ExternalId] = vm
+	}
+
+	var results []VmTagResult
+	added := map[string]bool{}
+	add := func(vm VirtualMachine) {
+		if added[vm.ExternalId] {
+			return
+		}
+		added[vm.ExternalId] = true
+
+		result := VmTagResult{ExternalId: vm.ExternalId}
+		if vm.DisplayName != nil {
+			result.DisplayName = *vm.DisplayName
+		}
+		results = append(results, result)
+	}
+
+	for _, externalId := range selector.ExternalIds {
+		if vm, ok := vmsByExternalId[externalId]; ok {
+			add(vm)
+		} else if !added[externalId] {
+			added[externalId] = true
+			results = append(results, VmTagResult{ExternalId: externalId, Err: fmt.Errorf("VM %s: %w", externalId, ErrNotFound)})
+		}
+	}
+	for _, displayName := range selector.DisplayNames {
+		for _, vm := range vms {
+			if vm.DisplayName != nil && *vm.DisplayName == displayName {
+				add(vm)
+			}
+		}
+	}
+	for _, vm := range vms {
+		add(vm)
+	}
+
+	return results, nil
+}